@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// apiUsage is the JSON form of a Usage with its cost.
+type apiUsage struct {
+	InputTokens        int     `json:"input_tokens"`
+	OutputTokens       int     `json:"output_tokens"`
+	CacheWrite5mTokens int     `json:"cache_write_5m_tokens"`
+	CacheWrite1hTokens int     `json:"cache_write_1h_tokens"`
+	CacheReadTokens    int     `json:"cache_read_tokens"`
+	WebSearchRequests  int     `json:"web_search_requests"`
+	CostUSD            float64 `json:"cost_usd"`
+}
+
+type apiDay struct {
+	Date string `json:"date"`
+	apiUsage
+	Models map[string]apiUsage `json:"models"`
+}
+
+type apiModel struct {
+	Model string `json:"model"`
+	apiUsage
+}
+
+type apiProject struct {
+	Project string `json:"project"`
+	apiUsage
+	Models map[string]apiUsage `json:"models"`
+}
+
+type apiSession struct {
+	SessionID string    `json:"session_id"`
+	Project   string    `json:"project"`
+	Files     []string  `json:"files"`
+	Requests  int       `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	apiUsage
+	Models map[string]apiUsage `json:"models"`
+}
+
+type apiProjections struct {
+	DaysSampled int                 `json:"days_sampled"`
+	DaysInMonth int                 `json:"days_in_month"`
+	Stats       []render.Projection `json:"stats"`
+}
+
+// toAPIUsage converts usage for the models in day to its JSON form, summed.
+func toAPIUsage(day *usage.DayUsage, prices pricing.Table, mode usage.CostMode) apiUsage {
+	var total usage.Usage
+	for _, u := range day.Models {
+		total.Merge(u)
+	}
+	return apiUsage{
+		InputTokens:        total.Input,
+		OutputTokens:       total.Output,
+		CacheWrite5mTokens: total.CacheWrite,
+		CacheWrite1hTokens: total.CacheWrite1h,
+		CacheReadTokens:    total.CacheRead,
+		WebSearchRequests:  total.WebSearchRequests,
+		CostUSD:            mode.Cost(day, prices),
+	}
+}
+
+// toAPIModels converts each model's usage to its JSON form.
+func toAPIModels(models map[string]*usage.Usage, prices pricing.Table, mode usage.CostMode) map[string]apiUsage {
+	out := make(map[string]apiUsage, len(models))
+	for model, u := range models {
+		out[model] = toAPIUsage(&usage.DayUsage{Models: map[string]*usage.Usage{model: u}}, prices, mode)
+	}
+	return out
+}
+
+// cacheFingerprint hashes the path, mtime and size of every cached file, so it
+// changes exactly when the underlying data does and is stable across restarts.
+func cacheFingerprint(cache *store.Cache) string {
+	h := fnv.New64a()
+	for _, path := range cache.SortedPaths() {
+		fc := cache.Files[path]
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, fc.ModTime, fc.Size)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// dateRange parses the since and until query parameters (YYYY-MM-DD, inclusive).
+func dateRange(r *http.Request) (since, until string, err error) {
+	since, until = r.URL.Query().Get("since"), r.URL.Query().Get("until")
+	for _, d := range []string{since, until} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return "", "", fmt.Errorf("invalid date %q, want YYYY-MM-DD", d)
+		}
+	}
+	return since, until, nil
+}
+
+// serveJSON writes v as JSON with an ETag derived from the cache fingerprint and
+// the current date (projections and default ranges depend on it). Matching
+// If-None-Match requests get 304 without a body.
+func serveJSON(w http.ResponseWriter, r *http.Request, fingerprint string, v any) {
+	etag := fmt.Sprintf(`"%s-%s"`, fingerprint, time.Now().UTC().Format("20060102"))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			if c := strings.TrimSpace(candidate); c == etag || c == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func (s *usageServer) handleDaily(w http.ResponseWriter, r *http.Request) {
+	since, until, err := dateRange(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.RLock()
+	days := []apiDay{}
+	for date, day := range usage.RollupDays(s.cache.Rollups, since) {
+		if !usage.InRange(date, since, until) {
+			continue
+		}
+		days = append(days, apiDay{
+			Date:     date,
+			apiUsage: toAPIUsage(day, pricing.Default, s.costMode),
+			Models:   toAPIModels(day.Models, pricing.Default, s.costMode),
+		})
+	}
+	fingerprint := s.fingerprint
+	s.mu.RUnlock()
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	serveJSON(w, r, fingerprint, days)
+}
+
+func (s *usageServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	since, until, err := dateRange(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.RLock()
+	totals, _ := usage.SumRollups(s.cache.Rollups, since, until)
+	fingerprint := s.fingerprint
+	s.mu.RUnlock()
+
+	models := []apiModel{}
+	for model, u := range toAPIModels(totals, pricing.Default, s.costMode) {
+		models = append(models, apiModel{Model: model, apiUsage: u})
+	}
+	sort.Slice(models, func(i, j int) bool {
+		if models[i].CostUSD != models[j].CostUSD {
+			return models[i].CostUSD > models[j].CostUSD
+		}
+		return models[i].Model < models[j].Model
+	})
+	serveJSON(w, r, fingerprint, models)
+}
+
+func (s *usageServer) handleProjects(w http.ResponseWriter, r *http.Request) {
+	since, until, err := dateRange(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.RLock()
+	_, totals := usage.SumRollups(s.cache.Rollups, since, until)
+	fingerprint := s.fingerprint
+	s.mu.RUnlock()
+
+	projects := []apiProject{}
+	for project, models := range totals {
+		projects = append(projects, apiProject{
+			Project:  project,
+			apiUsage: toAPIUsage(&usage.DayUsage{Models: models}, pricing.Default, s.costMode),
+			Models:   toAPIModels(models, pricing.Default, s.costMode),
+		})
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].CostUSD != projects[j].CostUSD {
+			return projects[i].CostUSD > projects[j].CostUSD
+		}
+		return projects[i].Project < projects[j].Project
+	})
+	serveJSON(w, r, fingerprint, projects)
+}
+
+// handleSession reports usage for one session: its transcript and any subagent
+// transcripts stored in the directory of the same name.
+func (s *usageServer) handleSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		writeAPIError(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	session := apiSession{SessionID: id, Files: []string{}}
+	entries := make(map[string]*usage.Entry)
+	var stats usage.MergeStats
+
+	s.mu.RLock()
+	var sessionDirs []string
+	for path := range s.cache.Files {
+		if filepath.Base(path) == id+".jsonl" {
+			sessionDirs = append(sessionDirs, strings.TrimSuffix(path, ".jsonl")+string(filepath.Separator))
+		}
+	}
+	for _, path := range s.cache.SortedPaths() {
+		matched := filepath.Base(path) == id+".jsonl"
+		for _, dir := range sessionDirs {
+			matched = matched || strings.HasPrefix(path, dir)
+		}
+		if !matched {
+			continue
+		}
+		session.Files = append(session.Files, path)
+		fc := s.cache.Files[path]
+		for i := range fc.Entries {
+			s.cache.Strategy.Merge(entries, &fc.Entries[i], &stats)
+		}
+	}
+	fingerprint := s.fingerprint
+	s.mu.RUnlock()
+
+	if len(session.Files) == 0 {
+		writeAPIError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	models := make(map[string]*usage.Usage)
+	for _, e := range entries {
+		if session.Project == "" {
+			session.Project = e.Project
+		}
+		t := time.Unix(e.Timestamp, 0).UTC()
+		if session.FirstSeen.IsZero() || t.Before(session.FirstSeen) {
+			session.FirstSeen = t
+		}
+		if t.After(session.LastSeen) {
+			session.LastSeen = t
+		}
+		if models[e.Model] == nil {
+			models[e.Model] = &usage.Usage{}
+		}
+		models[e.Model].Add(e)
+	}
+	session.Requests = len(entries)
+	session.apiUsage = toAPIUsage(&usage.DayUsage{Models: models}, pricing.Default, s.costMode)
+	session.Models = toAPIModels(models, pricing.Default, s.costMode)
+	serveJSON(w, r, fingerprint, session)
+}
+
+// handleProjections returns the month-to-date projections shown under the table.
+func (s *usageServer) handleProjections(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	dayUsage := usage.RollupDays(s.cache.Rollups, reportOptions{}.cutoff())
+	fingerprint := s.fingerprint
+	s.mu.RUnlock()
+
+	var dates []string
+	for d := range dayUsage {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+	dailyCosts := make([]float64, len(dates))
+	for i, d := range dates {
+		dailyCosts[i] = s.costMode.Cost(dayUsage[d], pricing.Default)
+	}
+	stats, daysInMonth := render.Projections(dailyCosts)
+	if stats == nil {
+		stats = []render.Projection{}
+	}
+	serveJSON(w, r, fingerprint, apiProjections{
+		DaysSampled: len(dailyCosts),
+		DaysInMonth: daysInMonth,
+		Stats:       stats,
+	})
+}
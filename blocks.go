@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// billingWindow is the length of a Claude subscription usage window.
+const billingWindow = 5 * time.Hour
+
+// billingBlock is a run of requests counted against one usage window. A block
+// starts at the hour of its first request and ends billingWindow later; a request
+// after the end, or after a gap of a full window, starts a new block.
+type billingBlock struct {
+	Start     time.Time
+	End       time.Time
+	LastEntry time.Time
+	Entries   []*usage.Entry
+}
+
+// billingBlocks groups entries into billing blocks in chronological order.
+func billingBlocks(entries map[string]*usage.Entry) []*billingBlock {
+	sorted := make([]*usage.Entry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Timestamp != sorted[j].Timestamp {
+			return sorted[i].Timestamp < sorted[j].Timestamp
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	var blocks []*billingBlock
+	var cur *billingBlock
+	for _, e := range sorted {
+		t := time.Unix(e.Timestamp, 0).UTC()
+		if cur == nil || !t.Before(cur.End) || t.Sub(cur.LastEntry) >= billingWindow {
+			start := t.Truncate(time.Hour)
+			cur = &billingBlock{Start: start, End: start.Add(billingWindow)}
+			blocks = append(blocks, cur)
+		}
+		cur.Entries = append(cur.Entries, e)
+		cur.LastEntry = t
+	}
+	return blocks
+}
+
+// activeBlock returns the block still open at now, or nil.
+func activeBlock(blocks []*billingBlock, now time.Time) *billingBlock {
+	if len(blocks) == 0 {
+		return nil
+	}
+	last := blocks[len(blocks)-1]
+	if now.Before(last.End) {
+		return last
+	}
+	return nil
+}
+
+// currentBlock returns the block open at now, or nil. Blocks chain from the
+// first request after a gap of a full window, so the open block can start long
+// before the last window; currentBlock walks back through the cache's day
+// index a day at a time until it finds that gap, merging only the days of the
+// current run of requests. RefreshRollups must have run.
+func currentBlock(cache *store.Cache, now time.Time) *billingBlock {
+	day := now.UTC().Truncate(24 * time.Hour)
+	var dates []string
+	for {
+		dates = append(dates, day.Format("2006-01-02"))
+		var stats usage.MergeStats
+		entries := cache.DedupDays(dates, &stats)
+		if start, ok := runStart(entries, day, now); ok {
+			if start < 0 {
+				return nil
+			}
+			for key, e := range entries {
+				if e.Timestamp < start || e.Timestamp > now.Unix() {
+					delete(entries, key)
+				}
+			}
+			return activeBlock(billingBlocks(entries), now)
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+}
+
+// runStart returns the timestamp of the first request of the run still open
+// at now, looking only at entries since from. It reports false when the run
+// may extend before from, and a negative start when no run is open.
+func runStart(entries map[string]*usage.Entry, from, now time.Time) (int64, bool) {
+	var times []int64
+	for _, e := range entries {
+		if e.Timestamp >= from.Unix() && e.Timestamp <= now.Unix() {
+			times = append(times, e.Timestamp)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	window := int64(billingWindow / time.Second)
+	next := now.Unix()
+	for i := len(times) - 1; i >= 0; i-- {
+		if next-times[i] >= window {
+			if i == len(times)-1 {
+				return -1, true
+			}
+			return next, true
+		}
+		next = times[i]
+	}
+	if next-from.Unix() >= window {
+		if len(times) == 0 {
+			return -1, true
+		}
+		return next, true
+	}
+	return 0, false
+}
+
+// blockCost returns the total cost of the requests in a block.
+func blockCost(b *billingBlock, prices pricing.Table, mode usage.CostMode) float64 {
+	var total float64
+	for _, e := range b.Entries {
+		total += mode.EntryCost(e, prices)
+	}
+	return total
+}
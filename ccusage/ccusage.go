@@ -0,0 +1,140 @@
+// Package ccusage loads Claude Code usage logs and answers cost and token
+// queries over them, sharing the CLI's on-disk cache.
+//
+// A program embedding it loads the logs once and queries the dataset:
+//
+//	ds, err := ccusage.Load(ctx, ccusage.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	month := ds.Range("2025-06-01", "")
+//	for date, day := range month.Daily() {
+//		fmt.Printf("%s %s\n", date, render.FormatDollars(month.Cost(day)))
+//	}
+//	fmt.Printf("total %s\n", render.FormatDollars(month.TotalCost()))
+//
+// Grouping by any entry field works the same way:
+//
+//	byBranch := ds.GroupBy(func(e *usage.Entry) string { return e.Branch })
+//	bySource := ds.GroupBy(func(e *usage.Entry) string { return e.Source })
+package ccusage
+
+import (
+	"context"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// Options controls where usage is read from and how it is priced.
+type Options struct {
+	// ConfigDirs are the Claude config directories to scan. Empty uses
+	// CLAUDE_CONFIG_DIR or the default locations, like the CLI.
+	ConfigDirs []string
+	// APILogDirs hold Anthropic API response dumps. Empty uses
+	// CCUSAGE_API_LOG_DIR.
+	APILogDirs []string
+	// Sources replaces the default sources built from ConfigDirs and
+	// APILogDirs, e.g. to add a custom source.
+	Sources source.Set
+	// NoCache ignores the existing cache and reparses every file.
+	NoCache bool
+	// ReadOnly leaves the cache file untouched after loading.
+	ReadOnly bool
+	// Pricing prices requests; nil uses pricing.Default.
+	Pricing pricing.Table
+	// Dedup picks the copy counted for requests logged more than once; empty
+	// uses usage.DedupMaxTokens.
+	Dedup usage.Dedup
+	// CostMode decides whether costs logged by older Claude Code versions are
+	// used; empty uses usage.CostAuto.
+	CostMode usage.CostMode
+}
+
+// Dataset is a set of deduplicated requests and the prices used to cost them.
+type Dataset struct {
+	entries map[string]*usage.Entry
+	prices  pricing.Table
+	mode    usage.CostMode
+}
+
+// Load discovers and parses the usage logs, refreshing the cache so later
+// loads only reparse changed files. Failing to save the cache is not an error.
+func Load(ctx context.Context, opts Options) (*Dataset, error) {
+	prices := opts.Pricing
+	if prices == nil {
+		prices = pricing.Default
+	}
+	dedup := opts.Dedup
+	if dedup == "" {
+		dedup = usage.DedupMaxTokens
+	}
+	cache, valid := store.Open(opts.NoCache, false)
+	cache.SetDedup(dedup)
+	sources := opts.Sources
+	if sources == nil {
+		sources = source.Defaults(opts.ConfigDirs, opts.APILogDirs)
+	}
+	changed, err := cache.Refresh(ctx, sources, valid)
+	if err != nil {
+		return nil, err
+	}
+	if changed && !opts.ReadOnly {
+		_ = cache.Save()
+	}
+	var stats usage.MergeStats
+	return &Dataset{entries: cache.Dedup(&stats), prices: prices, mode: opts.CostMode}, nil
+}
+
+// Entries returns the requests keyed by dedup key. The map must not be modified.
+func (d *Dataset) Entries() map[string]*usage.Entry {
+	return d.entries
+}
+
+// Range returns the requests dated in [since, until] (YYYY-MM-DD). An empty
+// since or until leaves that end of the range open.
+func (d *Dataset) Range(since, until string) *Dataset {
+	return d.Filter(func(e *usage.Entry) bool { return usage.InRange(e.Date, since, until) })
+}
+
+// Filter returns the requests for which keep returns true.
+func (d *Dataset) Filter(keep func(*usage.Entry) bool) *Dataset {
+	filtered := make(map[string]*usage.Entry)
+	for key, e := range d.entries {
+		if keep(e) {
+			filtered[key] = e
+		}
+	}
+	return &Dataset{entries: filtered, prices: d.prices, mode: d.mode}
+}
+
+// Daily returns per-model usage for each date.
+func (d *Dataset) Daily() map[string]*usage.DayUsage {
+	return usage.Aggregate(d.entries)
+}
+
+// GroupBy returns per-model usage for each group named by key.
+func (d *Dataset) GroupBy(key func(*usage.Entry) string) map[string]*usage.DayUsage {
+	return usage.AggregateBy(d.entries, key)
+}
+
+// Cost returns the cost of one group returned by Daily or GroupBy.
+func (d *Dataset) Cost(day *usage.DayUsage) float64 {
+	return d.mode.Cost(day, d.prices)
+}
+
+// EntryCost returns the cost of a single request.
+func (d *Dataset) EntryCost(e *usage.Entry) float64 {
+	return d.mode.EntryCost(e, d.prices)
+}
+
+// TotalCost returns the cost of every request in the dataset.
+func (d *Dataset) TotalCost() float64 {
+	var total float64
+	for _, day := range d.Daily() {
+		total += d.Cost(day)
+	}
+	return total
+}
@@ -0,0 +1,77 @@
+package ccusage_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/abatilo/ccusage-go/ccusage"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/source"
+)
+
+// transcript is a Claude Code session with two requests on one day and one on
+// the next.
+const transcript = `{"type":"assistant","timestamp":"2026-06-01T12:00:00.000Z","requestId":"req_1","sessionId":"s","message":{"id":"msg_1","model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1000000,"output_tokens":0}}}
+{"type":"assistant","timestamp":"2026-06-01T13:00:00.000Z","requestId":"req_2","sessionId":"s","message":{"id":"msg_2","model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":0,"output_tokens":100000}}}
+{"type":"assistant","timestamp":"2026-06-02T12:00:00.000Z","requestId":"req_3","sessionId":"s","message":{"id":"msg_3","model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":500000,"output_tokens":0}}}
+`
+
+// exampleOptions writes transcript into a temporary config directory and
+// returns options reading only it, leaving the user's cache untouched.
+func exampleOptions() (ccusage.Options, func()) {
+	dir, err := os.MkdirTemp("", "ccusage-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	project := filepath.Join(dir, "projects", "-home-u-proj")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "s.jsonl"), []byte(transcript), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	opts := ccusage.Options{
+		Sources:  source.Set{source.NewClaudeCode([]string{dir})},
+		NoCache:  true,
+		ReadOnly: true,
+	}
+	return opts, func() { os.RemoveAll(dir) }
+}
+
+func ExampleLoad() {
+	opts, cleanup := exampleOptions()
+	defer cleanup()
+
+	ds, err := ccusage.Load(context.Background(), opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d requests, %s\n", len(ds.Entries()), render.FormatDollars(ds.TotalCost()))
+	// Output: 3 requests, $6.00
+}
+
+func ExampleDataset_Daily() {
+	opts, cleanup := exampleOptions()
+	defer cleanup()
+
+	ds, err := ccusage.Load(context.Background(), opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	daily := ds.Daily()
+	dates := make([]string, 0, len(daily))
+	for date := range daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		fmt.Printf("%s %s\n", date, render.FormatDollars(ds.Cost(daily[date])))
+	}
+	// Output:
+	// 2026-06-01 $4.50
+	// 2026-06-02 $1.50
+}
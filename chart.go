@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// chartFlag is the --chart flag. Given bare it selects the cost chart; a mode can
+// be given as --chart=tokens or, via chartArgs, as --chart tokens.
+type chartFlag string
+
+func (c *chartFlag) String() string { return string(*c) }
+
+func (c *chartFlag) Set(v string) error {
+	switch v {
+	case "true":
+		v = "cost"
+	case "false":
+		v = ""
+	case "cost", "tokens":
+	default:
+		return fmt.Errorf("must be cost or tokens")
+	}
+	*c = chartFlag(v)
+	return nil
+}
+
+func (c *chartFlag) IsBoolFlag() bool { return true }
+
+// chartArgs joins "--chart cost" and "--chart tokens" into one argument before
+// parsing, since a bool-style flag never consumes the argument after it.
+func chartArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+		if (a == "--chart" || a == "-chart") && i+1 < len(args) && (args[i+1] == "cost" || args[i+1] == "tokens") {
+			a += "=" + args[i+1]
+			i++
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+const sparkTicks = "▁▂▃▄▅▆▇█"
+
+// tokenChartGlyphs are the fill characters for input, output, cache write and
+// cache read in the stacked token chart, so it reads without color.
+var tokenChartGlyphs = []string{"█", "▓", "▒", "░"}
+
+// outputWidth returns the terminal width of stdout, falling back to $COLUMNS and
+// then to the table width.
+func outputWidth() int {
+	if w, _, err := terminalSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 126
+}
+
+// printChart renders charts below the table: per-day bars from dailyCosts (in
+// date order, as returned by render.Table) or stacked token categories, followed
+// by a sparkline per model.
+func printChart(mode string, dayUsage map[string]*usage.DayUsage, dailyCosts []float64, costMode usage.CostMode) {
+	var dates []string
+	for d := range dayUsage {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+	if len(dates) == 0 {
+		return
+	}
+	width := max(outputWidth(), 40)
+
+	if mode == "tokens" {
+		printTokenChart(dates, dayUsage, width)
+	} else {
+		fmt.Printf("\nDaily cost\n")
+		maxCost := 0.0
+		for _, c := range dailyCosts {
+			maxCost = max(maxCost, c)
+		}
+		barWidth := width - len(dates[0]) - 15
+		for i, date := range dates {
+			bar := renderBar(dailyCosts[i], maxCost, barWidth)
+			fmt.Printf("%s %s %12s\n", date, padWidth(bar, barWidth), render.FormatDollars(dailyCosts[i]))
+		}
+	}
+
+	// One sparkline per model across the same dates
+	models := make(map[string]bool)
+	for _, day := range dayUsage {
+		for model := range day.Models {
+			models[model] = true
+		}
+	}
+	type modelSeries struct {
+		name   string
+		values []float64
+		total  float64
+	}
+	var series []modelSeries
+	labelWidth := 0
+	for model := range models {
+		s := modelSeries{name: model, values: make([]float64, len(dates))}
+		for i, date := range dates {
+			u := dayUsage[date].Models[model]
+			if u == nil {
+				continue
+			}
+			if mode == "tokens" {
+				s.values[i] = float64(u.Input + u.Output + u.CacheWrite + u.CacheWrite1h + u.CacheRead)
+			} else {
+				s.values[i] = costMode.Cost(&usage.DayUsage{Models: map[string]*usage.Usage{model: u}}, pricing.Default)
+			}
+			s.total += s.values[i]
+		}
+		series = append(series, s)
+		labelWidth = max(labelWidth, min(utf8.RuneCountInString(model), 32))
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].total != series[j].total {
+			return series[i].total > series[j].total
+		}
+		return series[i].name < series[j].name
+	})
+
+	title := "Cost by model"
+	if mode == "tokens" {
+		title = "Tokens by model"
+	}
+	fmt.Printf("\n%s (%s to %s)\n", title, dates[0], dates[len(dates)-1])
+	sparkWidth := max(width-labelWidth-19, 8)
+	for _, s := range series {
+		total := render.FormatDollars(s.total)
+		if mode == "tokens" {
+			total = render.FormatNumber(int(s.total))
+		}
+		fmt.Printf("%s  %s %16s\n", padWidth(s.name, labelWidth), padWidth(sparkline(s.values, sparkWidth), sparkWidth), total)
+	}
+}
+
+// printTokenChart renders one horizontal bar per day, stacked by token category.
+func printTokenChart(dates []string, dayUsage map[string]*usage.DayUsage, width int) {
+	fmt.Printf("\nDaily tokens  %s input  %s output  %s cache write  %s cache read\n",
+		tokenChartGlyphs[0], tokenChartGlyphs[1], tokenChartGlyphs[2], tokenChartGlyphs[3])
+	totals := make([][4]int, len(dates))
+	maxTotal := 0
+	for i, date := range dates {
+		input, output, cacheWrite, cacheRead := usage.SumTokens(dayUsage[date])
+		totals[i] = [4]int{input, output, cacheWrite, cacheRead}
+		maxTotal = max(maxTotal, input+output+cacheWrite+cacheRead)
+	}
+	barWidth := width - len(dates[0]) - 20
+	for i, date := range dates {
+		var bar strings.Builder
+		cum, drawn := 0, 0
+		for c, v := range totals[i] {
+			cum += v
+			// Round cumulative ends so segments never drift past the day's total
+			end := 0
+			if maxTotal > 0 {
+				end = int(math.Round(float64(cum) / float64(maxTotal) * float64(barWidth)))
+			}
+			bar.WriteString(strings.Repeat(tokenChartGlyphs[c], max(end-drawn, 0)))
+			drawn = max(drawn, end)
+		}
+		fmt.Printf("%s %s %17s\n", date, padWidth(bar.String(), barWidth), render.FormatNumber(cum))
+	}
+}
+
+// sparkline renders values as block characters scaled to the largest value,
+// summing neighbouring values into buckets when there are more than width and
+// widening each value to an equal number of cells when there are fewer.
+// Zero values render as spaces so idle days stand out.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		buckets := make([]float64, width)
+		for i, v := range values {
+			buckets[i*width/len(values)] += v
+		}
+		values = buckets
+	}
+	maxValue := 0.0
+	for _, v := range values {
+		maxValue = max(maxValue, v)
+	}
+	cells := max(width/max(len(values), 1), 1)
+	ticks := []rune(sparkTicks)
+	var b strings.Builder
+	for _, v := range values {
+		tick := " "
+		if v > 0 && maxValue > 0 {
+			idx := int(math.Ceil(v/maxValue*float64(len(ticks)))) - 1
+			tick = string(ticks[max(min(idx, len(ticks)-1), 0)])
+		}
+		b.WriteString(strings.Repeat(tick, cells))
+	}
+	return b.String()
+}
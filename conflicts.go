@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// conflictCopy is one copy of a request logged more than once, in several
+// files or several times in one.
+type conflictCopy struct {
+	File             string        `json:"file"`
+	Timestamp        int64         `json:"timestamp"`
+	Model            string        `json:"model"`
+	InputTokens      int           `json:"input_tokens"`
+	OutputTokens     int           `json:"output_tokens"`
+	CacheWriteTokens int           `json:"cache_write_tokens"`
+	CacheReadTokens  int           `json:"cache_read_tokens"`
+	Tokens           int           `json:"tokens"`
+	CostUSD          float64       `json:"cost_usd"`
+	KeptBy           []usage.Dedup `json:"kept_by"` // strategies that count this copy
+}
+
+// conflict is a request whose copies disagree on usage.
+type conflict struct {
+	Key         string         `json:"key"`
+	Date        string         `json:"date"`
+	TokenSpread int            `json:"token_spread"` // most minus fewest tokens across copies
+	CostSpread  float64        `json:"cost_spread_usd"`
+	InFile      bool           `json:"in_file"` // some copies share a file
+	Copies      []conflictCopy `json:"copies"`
+}
+
+// strategyTotal is what the report range adds up to under one strategy.
+type strategyTotal struct {
+	Strategy usage.Dedup `json:"strategy"`
+	Requests int         `json:"requests"`
+	Tokens   int         `json:"tokens"`
+	CostUSD  float64     `json:"cost_usd"`
+}
+
+type conflictReport struct {
+	Range      string          `json:"range"`
+	Strategy   usage.Dedup     `json:"strategy"`
+	Requests   int             `json:"requests"`           // distinct keys in range
+	Colliding  int             `json:"colliding"`          // keys logged more than once
+	InFile     int             `json:"in_file"`            // colliding keys with several copies in one file
+	Differing  int             `json:"differing"`          // colliding keys whose copies disagree on usage
+	MessageIDs int             `json:"shared_message_ids"` // message IDs logged under several request IDs
+	Strategies []strategyTotal `json:"strategies"`
+	Conflicts  []conflict      `json:"conflicts"`
+}
+
+func buildConflictReport(cache *store.Cache, cutoff string, top int, mode usage.CostMode) *conflictReport {
+	type copyOf struct {
+		file string
+		e    *usage.Entry
+	}
+	// Merge in path order and then file order, like Cache.Dedup, so every
+	// strategy keeps the same copies it does in the reports
+	copies := make(map[string][]copyOf)
+	winners := make(map[usage.Dedup]map[string]*usage.Entry, len(usage.DedupStrategies))
+	for _, d := range usage.DedupStrategies {
+		winners[d] = make(map[string]*usage.Entry)
+	}
+	var stats usage.MergeStats
+	for _, path := range cache.SortedPaths() {
+		fc := cache.Files[path]
+		for i := range fc.Entries {
+			e := &fc.Entries[i]
+			copies[e.Key] = append(copies[e.Key], copyOf{path, e})
+			for _, d := range usage.DedupStrategies {
+				d.Merge(winners[d], e, &stats)
+			}
+		}
+	}
+
+	r := &conflictReport{Strategy: cache.Strategy, Strategies: []strategyTotal{}, Conflicts: []conflict{}}
+	for _, d := range usage.DedupStrategies {
+		t := strategyTotal{Strategy: d}
+		for _, e := range usage.Since(winners[d], cutoff) {
+			t.Requests++
+			t.Tokens += e.TotalTokens()
+			t.CostUSD += mode.EntryCost(e, pricing.Default)
+		}
+		r.Strategies = append(r.Strategies, t)
+	}
+
+	requestIDs := make(map[string]int)
+	for key, cs := range copies {
+		inRange := false
+		for _, c := range cs {
+			inRange = inRange || c.e.Date >= cutoff
+		}
+		if !inRange {
+			continue
+		}
+		r.Requests++
+		requestIDs[usage.DedupMessageID.Key(cs[0].e)]++
+		if len(cs) < 2 {
+			continue
+		}
+		r.Colliding++
+		// Copies of one file are adjacent, as files are merged in turn
+		inFile, differ := false, false
+		for i, c := range cs[1:] {
+			inFile = inFile || c.file == cs[i].file
+			differ = differ || !c.e.SameUsage(cs[0].e)
+		}
+		if inFile {
+			r.InFile++
+		}
+		if !differ {
+			continue
+		}
+		r.Differing++
+
+		cf := conflict{Key: key, Date: cs[0].e.Date, InFile: inFile, Copies: make([]conflictCopy, 0, len(cs))}
+		minTokens, maxTokens := cs[0].e.TotalTokens(), cs[0].e.TotalTokens()
+		minCost, maxCost := mode.EntryCost(cs[0].e, pricing.Default), mode.EntryCost(cs[0].e, pricing.Default)
+		for _, c := range cs {
+			cc := conflictCopy{
+				File:             c.file,
+				Timestamp:        c.e.Timestamp,
+				Model:            c.e.Model,
+				InputTokens:      c.e.InputTokens,
+				OutputTokens:     c.e.OutputTokens,
+				CacheWriteTokens: c.e.CacheCreationTokens + c.e.CacheWrite1hTokens,
+				CacheReadTokens:  c.e.CacheReadTokens,
+				Tokens:           c.e.TotalTokens(),
+				CostUSD:          mode.EntryCost(c.e, pricing.Default),
+				KeptBy:           []usage.Dedup{},
+			}
+			for _, d := range usage.DedupStrategies {
+				if winners[d][d.Key(c.e)] == c.e {
+					cc.KeptBy = append(cc.KeptBy, d)
+				}
+			}
+			minTokens, maxTokens = min(minTokens, cc.Tokens), max(maxTokens, cc.Tokens)
+			minCost, maxCost = min(minCost, cc.CostUSD), max(maxCost, cc.CostUSD)
+			cf.Copies = append(cf.Copies, cc)
+		}
+		cf.TokenSpread, cf.CostSpread = maxTokens-minTokens, maxCost-minCost
+		r.Conflicts = append(r.Conflicts, cf)
+	}
+	for _, n := range requestIDs {
+		if n > 1 {
+			r.MessageIDs++
+		}
+	}
+
+	sort.Slice(r.Conflicts, func(i, j int) bool {
+		a, b := r.Conflicts[i], r.Conflicts[j]
+		if a.TokenSpread != b.TokenSpread {
+			return a.TokenSpread > b.TokenSpread
+		}
+		return a.Key < b.Key
+	})
+	if top > 0 {
+		r.Conflicts = r.Conflicts[:min(top, len(r.Conflicts))]
+	}
+	return r
+}
+
+func runConflicts(args []string) {
+	fs := flag.NewFlagSet("conflicts", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	top := fs.Int("top", 20, "number of conflicting requests to list (0 for all)")
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	opts := common.options(fs)
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+	if *top < 0 {
+		fmt.Fprintf(os.Stderr, "error: --top must not be negative\n")
+		os.Exit(1)
+	}
+
+	cache := common.loadCache(opts)
+	r := buildConflictReport(cache, opts.cutoff(), *top, opts.costMode)
+	r.Range = opts.describe()
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return
+	}
+	printConflictReport(r)
+}
+
+func printConflictReport(r *conflictReport) {
+	fmt.Printf("Requests logged more than once, %s\n\n", r.Range)
+	fmt.Printf("%s requests, %s logged more than once (%s within one file), %s with differing usage; %s message IDs logged under more than one request ID\n\n",
+		render.FormatNumber(r.Requests), render.FormatNumber(r.Colliding), render.FormatNumber(r.InFile), render.FormatNumber(r.Differing), render.FormatNumber(r.MessageIDs))
+
+	rowFormat := "%-17s %10s %17s %12s\n"
+	fmt.Printf(rowFormat, "Dedup", "Requests", "Tokens", "Cost")
+	fmt.Println(strings.Repeat("-", 59))
+	for _, t := range r.Strategies {
+		name := string(t.Strategy)
+		if t.Strategy == r.Strategy {
+			name += " *"
+		}
+		fmt.Printf(rowFormat, name, render.FormatNumber(t.Requests), render.FormatNumber(t.Tokens), render.FormatDollars(t.CostUSD))
+	}
+	fmt.Printf("\n* strategy in use (--dedup)\n")
+
+	if len(r.Conflicts) == 0 {
+		return
+	}
+	fmt.Printf("\nConflicting requests by token difference\n")
+	rowFormat = "  %-19s %12s %12s %12s %14s %10s  %s\n"
+	for _, c := range r.Conflicts {
+		note := ""
+		if c.InFile {
+			note = ", repeated within one file"
+		}
+		fmt.Printf("\n%s  %s  %s tokens, %s apart%s\n", c.Key, c.Date, render.FormatNumber(c.TokenSpread), render.FormatDollars(c.CostSpread), note)
+		fmt.Printf(rowFormat, "Time", "Input", "Output", "CacheWrite", "CacheRead", "Cost", "Kept by")
+		for _, cc := range c.Copies {
+			kept := make([]string, len(cc.KeptBy))
+			for i, d := range cc.KeptBy {
+				kept[i] = string(d)
+			}
+			keptBy := strings.Join(kept, ", ")
+			if keptBy == "" {
+				keptBy = "-"
+			}
+			fmt.Printf(rowFormat, time.Unix(cc.Timestamp, 0).Format("2006-01-02 15:04:05"), render.FormatNumber(cc.InputTokens),
+				render.FormatNumber(cc.OutputTokens), render.FormatNumber(cc.CacheWriteTokens), render.FormatNumber(cc.CacheReadTokens),
+				render.FormatDollars(cc.CostUSD), keptBy)
+			fmt.Printf("    %s\n", cc.File)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package discovery locates Claude config directories and the log files
+// beneath them.
+package discovery
+
+import (
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manifest records the directory tree seen by the last discovery pass, so warm
+// runs only stat directories instead of walking every root.
+type Manifest struct {
+	Dirs         map[string]int64 // directory -> mtime (Unix nanoseconds)
+	Roots        []string
+	LastFullWalk time.Time
+}
+
+// Stats describes the work done by one discovery pass.
+type Stats struct {
+	DirsChecked    int
+	DirsChanged    int
+	SubtreesWalked int
+	FilesFromCache int
+	FullWalk       bool
+}
+
+// SplitDirList splits a comma- or path-list-separated list of directories.
+func SplitDirList(v string) []string {
+	var dirs []string
+	for _, part := range strings.Split(v, ",") {
+		for _, dir := range filepath.SplitList(part) {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// ConfigDirs returns every Claude config directory to scan. Explicit dirs (each
+// may itself be a list) win over CLAUDE_CONFIG_DIR; otherwise both the XDG and
+// legacy locations are scanned when they contain projects, falling back to
+// ~/.claude.
+func ConfigDirs(flagDirs []string) []string {
+	var candidates []string
+	if len(flagDirs) > 0 {
+		for _, d := range flagDirs {
+			candidates = append(candidates, SplitDirList(d)...)
+		}
+	} else if env := os.Getenv("CLAUDE_CONFIG_DIR"); env != "" {
+		candidates = SplitDirList(env)
+	} else {
+		home, _ := os.UserHomeDir()
+		for _, d := range []string{filepath.Join(home, ".config", "claude"), filepath.Join(home, ".claude")} {
+			if _, err := os.Stat(filepath.Join(d, "projects")); err == nil {
+				candidates = append(candidates, d)
+			}
+		}
+		if len(candidates) == 0 {
+			candidates = []string{filepath.Join(home, ".claude")}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, d := range candidates {
+		if abs, err := filepath.Abs(d); err == nil {
+			d = abs
+		}
+		if !seen[d] {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// logSuffixes name the files read as logs: JSONL, optionally compressed with a
+// standard library decompressor, and tar archives of them.
+var logSuffixes = []string{".jsonl", ".jsonl.gz", ".jsonl.bz2", ".tar", ".tar.gz", ".tgz", ".tar.bz2"}
+
+// IsLog reports whether path names a log file or archive.
+func IsLog(path string) bool {
+	for _, suffix := range logSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fullWalk does a full filesystem walk, collecting both log files and directory mtimes.
+func fullWalk(dir string) (files []string, dirs map[string]int64) {
+	dirs = make(map[string]int64)
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			info, infoErr := d.Info()
+			if infoErr == nil {
+				dirs[path] = info.ModTime().UnixNano()
+			}
+			return nil
+		}
+		if IsLog(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return
+}
+
+// walkSubtree walks a single directory subtree and collects log files and dir mtimes.
+func walkSubtree(root string) (files []string, dirs map[string]int64) {
+	dirs = make(map[string]int64)
+	_ = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			info, infoErr := d.Info()
+			if infoErr == nil {
+				dirs[path] = info.ModTime().UnixNano()
+			}
+			return nil
+		}
+		if IsLog(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return
+}
+
+const fullWalkInterval = 5 * time.Minute
+
+// Find discovers log files under every root using the directory manifest m
+// when available; known lists the files found by the previous run. On cold
+// start, when the set of roots changed, or when the safety interval has
+// elapsed, it does a full walk. On warm start, it stats known directories and
+// only walks changed subtrees.
+func Find(roots []string, m *Manifest, known iter.Seq[string]) ([]string, Stats) {
+	var dStats Stats
+
+	// Cold start or safety net: full walk
+	needsFullWalk := m == nil ||
+		!m.Covers(roots) ||
+		time.Since(m.LastFullWalk) > fullWalkInterval
+
+	if needsFullWalk {
+		dStats.FullWalk = true
+		var files []string
+		dirs := make(map[string]int64)
+		for _, root := range roots {
+			rootFiles, rootDirs := fullWalk(root)
+			files = append(files, rootFiles...)
+			for d, mtime := range rootDirs {
+				dirs[d] = mtime
+			}
+		}
+		if m != nil {
+			m.Dirs = dirs
+			m.Roots = roots
+			m.LastFullWalk = time.Now()
+		}
+		dStats.DirsChecked = len(dirs)
+		return files, dStats
+	}
+
+	return Warm(m, known)
+}
+
+// Covers reports whether the manifest was built from roots, so Warm can be used
+// without a full walk.
+func (m *Manifest) Covers(roots []string) bool {
+	return len(m.Dirs) > 0 && slices.Equal(m.Roots, roots)
+}
+
+// Warm stats the directories in the manifest and only walks subtrees whose mtime
+// changed. Files in unchanged directories are taken from known.
+func Warm(m *Manifest, known iter.Seq[string]) ([]string, Stats) {
+	var dStats Stats
+	dStats.DirsChecked = len(m.Dirs)
+
+	// Separate dirs into changed vs unchanged
+	changedRoots := make(map[string]bool)
+	unchangedDirs := make(map[string]bool)
+
+	for dirPath, cachedMtime := range m.Dirs {
+		info, err := os.Stat(dirPath)
+		if err != nil {
+			changedRoots[dirPath] = true
+			dStats.DirsChanged++
+			continue
+		}
+		currentMtime := info.ModTime().UnixNano()
+		if currentMtime != cachedMtime {
+			changedRoots[dirPath] = true
+			dStats.DirsChanged++
+		} else {
+			unchangedDirs[dirPath] = true
+		}
+	}
+
+	// Collect files from unchanged directories using the known files
+	fileSet := make(map[string]bool)
+	for filePath := range known {
+		parentDir := filepath.Dir(filePath)
+		if unchangedDirs[parentDir] {
+			fileSet[filePath] = true
+			dStats.FilesFromCache++
+		}
+	}
+
+	// Walk changed subtrees
+	for _, root := range minimalRoots(changedRoots) {
+		dStats.SubtreesWalked++
+		subtreeFiles, subtreeDirs := walkSubtree(root)
+		for _, f := range subtreeFiles {
+			fileSet[f] = true
+		}
+		for d, mtime := range subtreeDirs {
+			m.Dirs[d] = mtime
+			delete(unchangedDirs, d)
+		}
+	}
+
+	// Clean up deleted directories from manifest
+	for dirPath := range m.Dirs {
+		if _, err := os.Stat(dirPath); err != nil {
+			delete(m.Dirs, dirPath)
+		}
+	}
+
+	files := make([]string, 0, len(fileSet))
+	for f := range fileSet {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	return files, dStats
+}
+
+// minimalRoots filters a set of directory paths to only the top-level roots,
+// removing any path that is a descendant of another path in the set.
+func minimalRoots(dirs map[string]bool) []string {
+	paths := make([]string, 0, len(dirs))
+	for d := range dirs {
+		paths = append(paths, d)
+	}
+	sort.Strings(paths)
+
+	var roots []string
+	for _, p := range paths {
+		isChild := false
+		for _, root := range roots {
+			if strings.HasPrefix(p, root+string(filepath.Separator)) {
+				isChild = true
+				break
+			}
+		}
+		if !isChild {
+			roots = append(roots, p)
+		}
+	}
+	return roots
+}
+
+// ProjectDirs returns the projects directory inside each config directory.
+func ProjectDirs(configDirs []string) []string {
+	projectDirs := make([]string, len(configDirs))
+	for i, dir := range configDirs {
+		projectDirs[i] = filepath.Join(dir, "projects")
+	}
+	return projectDirs
+}
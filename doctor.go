@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/parser"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/source"
+)
+
+// doctorReport sums the parse diagnostics of every transcript checked.
+type doctorReport struct {
+	Files         int                   `json:"files"`
+	Lines         int                   `json:"lines"`
+	Requests      int                   `json:"requests"`
+	Duplicates    int                   `json:"duplicate_lines"`
+	Skipped       map[string]int        `json:"skipped"`
+	Oversized     int                   `json:"oversized_lines"`
+	Stopped       int                   `json:"files_stopped_early"`
+	UnknownFields map[string]int        `json:"unknown_fields"`
+	Problems      []*parser.Diagnostics `json:"files_with_problems"`
+}
+
+func buildDoctorReport(diags []*parser.Diagnostics) *doctorReport {
+	r := &doctorReport{
+		Skipped:       make(map[string]int),
+		UnknownFields: make(map[string]int),
+		Problems:      []*parser.Diagnostics{},
+	}
+	for _, d := range diags {
+		r.Files++
+		r.Lines += d.Lines
+		r.Requests += d.Requests
+		r.Duplicates += d.Duplicates
+		r.Oversized += d.Oversized
+		for reason, n := range d.Skipped {
+			r.Skipped[reason] += n
+		}
+		for field, n := range d.UnknownFields {
+			r.UnknownFields[field] += n
+		}
+		if d.StoppedAt > 0 {
+			r.Stopped++
+		}
+		if d.Problems() {
+			r.Problems = append(r.Problems, d)
+		}
+	}
+	sort.Slice(r.Problems, func(i, j int) bool { return r.Problems[i].Path < r.Problems[j].Path })
+	return r
+}
+
+// diagnoseFile diagnoses a transcript or every transcript in an archive.
+func diagnoseFile(path string, samples int) []*parser.Diagnostics {
+	var diags []*parser.Diagnostics
+	err := source.Walk(path, func(key string, log source.Log) error {
+		d := parser.Diagnose(log, log.Name, samples)
+		d.Path = key
+		diags = append(diags, d)
+		return nil
+	})
+	if err != nil {
+		diags = append(diags, &parser.Diagnostics{Path: path, Skipped: map[string]int{}, ReadError: err.Error()})
+	}
+	return diags
+}
+
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	srcFlags := addSourceFlags(fs)
+	inputs := addInputFlags(fs)
+	samples := fs.Int("samples", 3, "offending lines to show per skip reason and file")
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	// Diagnose understands Claude Code transcripts only; say so rather than
+	// run other sources' logs through it
+	for _, name := range srcFlags.names {
+		for _, n := range strings.Split(name, ",") {
+			if n != source.ClaudeCode {
+				fmt.Fprintf(os.Stderr, "error: doctor checks %s transcripts only; --source %s is not supported\n", source.ClaudeCode, n)
+				os.Exit(1)
+			}
+		}
+	}
+	if len(srcFlags.apiLogDirs) > 0 {
+		fmt.Fprintf(os.Stderr, "error: doctor checks %s transcripts only; --api-log-dir is not supported\n", source.ClaudeCode)
+		os.Exit(1)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+
+	// Every file is reparsed; the cache only holds what parsed successfully
+	var files []string
+	if inputs.enabled() {
+		for _, arg := range inputs.files {
+			paths, err := inputPaths(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			files = append(files, paths...)
+		}
+	} else {
+		files, _ = discovery.Find(discovery.ProjectDirs(discovery.ConfigDirs(srcFlags.configDirs)), nil, nil)
+	}
+	sort.Strings(files)
+	var diags []*parser.Diagnostics
+	for _, path := range files {
+		diags = append(diags, diagnoseFile(path, *samples)...)
+	}
+	if *inputs.stdin {
+		diags = append(diags, parser.Diagnose(os.Stdin, stdinName, *samples))
+	}
+	r := buildDoctorReport(diags)
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return
+	}
+	printDoctorReport(r)
+}
+
+func printDoctorReport(r *doctorReport) {
+	skipped := 0
+	for _, n := range r.Skipped {
+		skipped += n
+	}
+	fmt.Printf("Checked %s transcripts, %s lines: %s requests, %s repeated request lines, %s lines skipped\n",
+		render.FormatNumber(r.Files), render.FormatNumber(r.Lines), render.FormatNumber(r.Requests),
+		render.FormatNumber(r.Duplicates), render.FormatNumber(skipped))
+
+	if skipped > 0 {
+		reasons := make([]string, 0, len(r.Skipped))
+		for reason := range r.Skipped {
+			reasons = append(reasons, reason)
+		}
+		sort.Slice(reasons, func(i, j int) bool {
+			if r.Skipped[reasons[i]] != r.Skipped[reasons[j]] {
+				return r.Skipped[reasons[i]] > r.Skipped[reasons[j]]
+			}
+			return reasons[i] < reasons[j]
+		})
+		fmt.Printf("\n%-40s %12s\n", "Skip reason", "Lines")
+		fmt.Println(strings.Repeat("-", 53))
+		for _, reason := range reasons {
+			note := ""
+			if parser.Benign(reason) {
+				note = "  (expected)"
+			}
+			fmt.Printf("%-40s %12s%s\n", reason, render.FormatNumber(r.Skipped[reason]), note)
+		}
+	}
+	if r.Oversized > 0 {
+		fmt.Printf("\n%s lines over 10MB were read with their long strings dropped.\n", render.FormatNumber(r.Oversized))
+	}
+	if r.Stopped > 0 {
+		fmt.Printf("\n%d transcripts stopped early; lines after the stop were never read.\n", r.Stopped)
+	}
+
+	if len(r.UnknownFields) > 0 {
+		fields := make([]string, 0, len(r.UnknownFields))
+		for field := range r.UnknownFields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		fmt.Printf("\n%-56s %12s\n", "Unknown field", "Lines")
+		fmt.Println(strings.Repeat("-", 69))
+		for _, field := range fields {
+			fmt.Printf("%-56s %12s\n", field, render.FormatNumber(r.UnknownFields[field]))
+		}
+	}
+
+	if len(r.Problems) == 0 {
+		fmt.Printf("\nNo problems found.\n")
+		return
+	}
+	fmt.Printf("\nFiles with problems\n")
+	for _, d := range r.Problems {
+		fmt.Printf("\n%s\n", d.Path)
+		fmt.Printf("  %s lines, %s requests\n", render.FormatNumber(d.Lines), render.FormatNumber(d.Requests))
+		if d.ReadError != "" {
+			fmt.Printf("  read error: %s\n", d.ReadError)
+		}
+		if d.StoppedAt > 0 {
+			fmt.Printf("  stopped at line %d; the rest of the file was not read\n", d.StoppedAt)
+		}
+		reasons := make([]string, 0, len(d.Skipped))
+		for reason := range d.Skipped {
+			if !parser.Benign(reason) {
+				reasons = append(reasons, reason)
+			}
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Printf("  %s: %s\n", reason, render.FormatNumber(d.Skipped[reason]))
+		}
+		if len(d.UnknownFields) > 0 {
+			fields := make([]string, 0, len(d.UnknownFields))
+			for field := range d.UnknownFields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			fmt.Printf("  unknown fields: %s\n", strings.Join(fields, ", "))
+		}
+		for _, s := range d.Samples {
+			fmt.Printf("    line %d (%s): %s\n", s.Line, s.Reason, s.Text)
+		}
+	}
+}
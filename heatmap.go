@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// heatmapWeekdays lists weekdays Monday first, the order rows are shown in.
+var heatmapWeekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// heatmapShades are the terminal cell fills from lowest to highest quartile.
+var heatmapShades = []string{"░", "▒", "▓", "█"}
+
+// heatmap holds usage bucketed by local weekday (Monday first) and hour.
+type heatmap struct {
+	Timezone string         `json:"timezone"`
+	Range    string         `json:"range"`
+	Weekdays []string       `json:"weekdays"`
+	Cost     [7][24]float64 `json:"cost_usd"`
+	Tokens   [7][24]int     `json:"tokens"`
+	Requests [7][24]int     `json:"requests"`
+}
+
+func buildHeatmap(entries map[string]*usage.Entry, loc *time.Location, mode usage.CostMode) *heatmap {
+	h := &heatmap{Timezone: loc.String()}
+	if loc == time.Local {
+		// "Local" says nothing in output; show the zone abbreviation instead
+		h.Timezone, _ = time.Now().In(loc).Zone()
+	}
+	for _, d := range heatmapWeekdays {
+		h.Weekdays = append(h.Weekdays, d.String()[:3])
+	}
+	for _, e := range entries {
+		t := time.Unix(e.Timestamp, 0).In(loc)
+		row := (int(t.Weekday()) + 6) % 7 // Monday = 0
+		h.Cost[row][t.Hour()] += mode.EntryCost(e, pricing.Default)
+		h.Tokens[row][t.Hour()] += e.TotalTokens()
+		h.Requests[row][t.Hour()]++
+	}
+	return h
+}
+
+func runHeatmap(args []string) {
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	metric := fs.String("metric", "cost", "value to shade cells by: cost, tokens")
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	opts := common.options(fs)
+	if *metric != "cost" && *metric != "tokens" {
+		fmt.Fprintf(os.Stderr, "error: --metric must be one of: cost, tokens\n")
+		os.Exit(1)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+
+	entries := common.loadEntries(opts)
+	h := buildHeatmap(entries, time.Local, opts.costMode)
+	h.Range = opts.describe()
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(h)
+		return
+	}
+	printHeatmap(h, *metric)
+}
+
+// printHeatmap renders the 7x24 grid with cells shaded by quartile of the
+// busiest cell, and the per-weekday total at the end of each row.
+func printHeatmap(h *heatmap, metric string) {
+	var values [7][24]float64
+	maxValue := 0.0
+	for d := range values {
+		for hour := range values[d] {
+			if metric == "tokens" {
+				values[d][hour] = float64(h.Tokens[d][hour])
+			} else {
+				values[d][hour] = h.Cost[d][hour]
+			}
+			maxValue = max(maxValue, values[d][hour])
+		}
+	}
+	format := func(v float64) string {
+		if metric == "tokens" {
+			return render.FormatNumber(int(v))
+		}
+		return render.FormatDollars(v)
+	}
+
+	fmt.Printf("%s by hour of day (%s), %s\n\n", map[string]string{"cost": "Cost", "tokens": "Tokens"}[metric], h.Timezone, h.Range)
+	fmt.Printf("%-4s", "")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Printf("%3d", hour)
+	}
+	fmt.Printf(" %15s\n", "Total")
+
+	var total float64
+	for d, name := range h.Weekdays {
+		var row strings.Builder
+		var rowTotal float64
+		for hour := 0; hour < 24; hour++ {
+			v := values[d][hour]
+			rowTotal += v
+			cell := "  "
+			if v > 0 {
+				idx := int(v / maxValue * float64(len(heatmapShades)))
+				cell = strings.Repeat(heatmapShades[min(idx, len(heatmapShades)-1)], 2)
+			}
+			row.WriteString(" " + cell)
+		}
+		total += rowTotal
+		fmt.Printf("%-4s%s %15s\n", name, row.String(), format(rowTotal))
+	}
+
+	fmt.Printf("\nLegend: %s  <25%%  %s  <50%%  %s  <75%%  %s  up to %s per cell, total %s\n",
+		heatmapShades[0], heatmapShades[1], heatmapShades[2], heatmapShades[3], format(maxValue), format(total))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// stdinName names transcripts read from stdin in the in-memory cache.
+const stdinName = "stdin"
+
+// inputFlags name transcripts to report on instead of the configured sources,
+// e.g. logs copied from CI runners. Inputs bypass the cache entirely.
+type inputFlags struct {
+	files stringList
+	stdin *bool
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	in := &inputFlags{
+		stdin: fs.Bool("stdin", false, "read JSONL transcripts from stdin instead of the config directories (skips the cache)"),
+	}
+	fs.Var(&in.files, "input", "JSONL transcript or directory of transcripts to read instead of the config directories (repeatable; skips the cache)")
+	return in
+}
+
+func (in *inputFlags) enabled() bool {
+	return len(in.files) > 0 || *in.stdin
+}
+
+// load parses the inputs into an in-memory cache with daily rollups. Entries
+// are deduplicated across inputs with strategy d exactly as across configured
+// log files.
+func (in *inputFlags) load(d usage.Dedup) (*store.Cache, error) {
+	cache := store.New()
+	cache.SetDedup(d)
+	add := func(name string, log source.Log) {
+		src, log := inputSource(log)
+		entries, _ := src.Parse(log)
+		fe := &store.FileEntry{Entries: make([]usage.Entry, 0, len(entries))}
+		for _, e := range entries {
+			e.Source = src.Name()
+			fe.Entries = append(fe.Entries, e)
+		}
+		cache.Files[name] = fe
+	}
+	for _, arg := range in.files {
+		paths, err := inputPaths(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			err := source.Walk(path, func(key string, log source.Log) error {
+				add(key, log)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	if *in.stdin {
+		add(stdinName, source.Log{Reader: os.Stdin, Name: stdinName})
+	}
+	var stats usage.MergeStats
+	cache.Rollups = usage.Rollup(cache.Dedup(&stats))
+	return cache, nil
+}
+
+// inputSource picks the source whose format log is in: Codex rollouts open
+// with a session_meta line, and anything else is read as a Claude Code
+// transcript. The returned log replays the bytes read to tell them apart.
+func inputSource(log source.Log) (source.Source, source.Log) {
+	r := bufio.NewReaderSize(log.Reader, 64*1024)
+	log.Reader = r
+	head, _ := r.Peek(r.Size())
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	var first struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(head, &first) == nil && first.Type == "session_meta" {
+		return source.NewCodex(source.CodexHome()), log
+	}
+	return source.NewClaudeCode(nil), log
+}
+
+// inputPaths expands an --input argument: a file is read as-is and a directory
+// contributes every log file or archive beneath it. Paths are made absolute so
+// the project is still derived from a copied projects/ tree.
+func inputPaths(arg string) ([]string, error) {
+	if abs, err := filepath.Abs(arg); err == nil {
+		arg = abs
+	}
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{arg}, nil
+	}
+	var paths []string
+	err = filepath.WalkDir(arg, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && discovery.IsLog(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
@@ -1,3 +1,3 @@
 #!/usr/bin/env bash
-go build -ldflags="-w -s" -o ccusage-go ./*.go
+go build -ldflags="-w -s" -o ccusage-go .
 mv ccusage-go ~/.local/bin/ccusage
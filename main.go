@@ -1,1051 +1,441 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/binary"
-	"encoding/gob"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"math"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
-	"runtime"
-	"sort"
 	"strings"
-	"sync"
 	"time"
-)
 
-type LogEntry struct {
-	Timestamp string `json:"timestamp"`
-	RequestID string `json:"requestId"`
-	Message   struct {
-		ID    string `json:"id"`
-		Model string `json:"model"`
-		Usage struct {
-			InputTokens         int `json:"input_tokens"`
-			OutputTokens        int `json:"output_tokens"`
-			CacheCreationTokens int `json:"cache_creation_input_tokens"`
-			CacheReadTokens     int `json:"cache_read_input_tokens"`
-			CacheCreation       struct {
-				Ephemeral5m int `json:"ephemeral_5m_input_tokens"`
-				Ephemeral1h int `json:"ephemeral_1h_input_tokens"`
-			} `json:"cache_creation"`
-			ServiceTier   string `json:"service_tier"`
-			Speed         string `json:"speed"`
-			ServerToolUse struct {
-				WebSearchRequests int `json:"web_search_requests"`
-			} `json:"server_tool_use"`
-		} `json:"usage"`
-	} `json:"message"`
-}
+	"github.com/abatilo/ccusage-go/ccusage"
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
 
-type ModelPricing struct {
-	Input        float64
-	Output       float64
-	CacheWrite   float64 // 5-minute ephemeral (1.25x base input)
-	CacheWrite1h float64 // 1-hour ephemeral (2x base input)
-	CacheRead    float64
-}
+// stringList is a flag.Value that collects every occurrence of a repeatable flag.
+type stringList []string
 
-type Usage struct {
-	Input             int
-	Output            int
-	CacheWrite        int
-	CacheWrite1h      int
-	CacheRead         int
-	WebSearchRequests int
-}
+func (s *stringList) String() string { return strings.Join(*s, ",") }
 
-type DayUsage struct {
-	Models map[string]*Usage
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
-func getConfigDir() string {
-	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
-		return dir
-	}
-	home, _ := os.UserHomeDir()
-	primary := filepath.Join(home, ".config", "claude")
-	if _, err := os.Stat(filepath.Join(primary, "projects")); err == nil {
-		return primary
+// filterProject keeps entries from project, given either as the log directory
+// name under projects/ or as the working directory the session ran in.
+func filterProject(entries map[string]*usage.Entry, project string) map[string]*usage.Entry {
+	dir, err := filepath.Abs(project)
+	if err != nil {
+		dir = project
 	}
-	return filepath.Join(home, ".claude")
-}
-
-// fullWalkJSONL does a full filesystem walk, collecting both JSONL files and directory mtimes.
-func fullWalkJSONL(dir string) (files []string, dirs map[string]int64) {
-	dirs = make(map[string]int64)
-	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			info, infoErr := d.Info()
-			if infoErr == nil {
-				dirs[path] = info.ModTime().UnixNano()
-			}
-			return nil
+	filtered := make(map[string]*usage.Entry)
+	for key, e := range entries {
+		if e.Project == project || (e.Cwd != "" && e.Cwd == dir) {
+			filtered[key] = e
 		}
-		if strings.HasSuffix(path, ".jsonl") {
-			files = append(files, path)
-		}
-		return nil
-	})
-	return
+	}
+	return filtered
 }
 
-// walkSubtree walks a single directory subtree and collects JSONL files and dir mtimes.
-func walkSubtree(root string) (files []string, dirs map[string]int64) {
-	dirs = make(map[string]int64)
-	_ = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			info, infoErr := d.Info()
-			if infoErr == nil {
-				dirs[path] = info.ModTime().UnixNano()
-			}
-			return nil
-		}
-		if strings.HasSuffix(path, ".jsonl") {
-			files = append(files, path)
-		}
-		return nil
-	})
-	return
+// sourceFlags select the log directories read by every subcommand.
+type sourceFlags struct {
+	names      stringList
+	configDirs stringList
+	apiLogDirs stringList
 }
 
-const fullWalkInterval = 5 * time.Minute
-
-// findJSONLFiles discovers JSONL files using the directory manifest when available.
-// On cold start or when the safety interval has elapsed, it does a full walk.
-// On warm start, it stats known directories and only walks changed subtrees.
-func findJSONLFiles(dir string, cache *CacheFile) ([]string, discoveryStats) {
-	var dStats discoveryStats
-
-	// Cold start or safety net: full walk
-	needsFullWalk := cache == nil ||
-		len(cache.Dirs) == 0 ||
-		time.Since(cache.LastFullWalk) > fullWalkInterval
-
-	if needsFullWalk {
-		dStats.fullWalk = true
-		files, dirs := fullWalkJSONL(dir)
-		if cache != nil {
-			cache.Dirs = dirs
-			cache.LastFullWalk = time.Now()
-		}
-		dStats.dirsChecked = len(dirs)
-		return files, dStats
-	}
-
-	// Warm start: stat known directories, walk only changed subtrees
-	dStats.dirsChecked = len(cache.Dirs)
-
-	// Separate dirs into changed vs unchanged
-	changedRoots := make(map[string]bool)
-	unchangedDirs := make(map[string]bool)
-
-	for dirPath, cachedMtime := range cache.Dirs {
-		info, err := os.Stat(dirPath)
-		if err != nil {
-			changedRoots[dirPath] = true
-			dStats.dirsChanged++
-			continue
-		}
-		currentMtime := info.ModTime().UnixNano()
-		if currentMtime != cachedMtime {
-			changedRoots[dirPath] = true
-			dStats.dirsChanged++
-		} else {
-			unchangedDirs[dirPath] = true
-		}
-	}
-
-	// Collect files from unchanged directories using the cache's known files
-	fileSet := make(map[string]bool)
-	for filePath := range cache.Files {
-		parentDir := filepath.Dir(filePath)
-		if unchangedDirs[parentDir] {
-			fileSet[filePath] = true
-			dStats.filesFromCache++
-		}
-	}
-
-	// Walk changed subtrees
-	roots := minimalRoots(changedRoots)
-	for _, root := range roots {
-		dStats.subtreesWalked++
-		subtreeFiles, subtreeDirs := walkSubtree(root)
-		for _, f := range subtreeFiles {
-			fileSet[f] = true
-		}
-		for d, mtime := range subtreeDirs {
-			cache.Dirs[d] = mtime
-			delete(unchangedDirs, d)
-		}
-	}
-
-	// Clean up deleted directories from manifest
-	for dirPath := range cache.Dirs {
-		if _, err := os.Stat(dirPath); err != nil {
-			delete(cache.Dirs, dirPath)
-		}
-	}
-
-	files := make([]string, 0, len(fileSet))
-	for f := range fileSet {
-		files = append(files, f)
-	}
-	sort.Strings(files)
-
-	return files, dStats
+func addSourceFlags(fs *flag.FlagSet) *sourceFlags {
+	s := &sourceFlags{}
+	fs.Var(&s.configDirs, "config-dir", "Claude config directory to scan (repeatable; overrides CLAUDE_CONFIG_DIR)")
+	fs.Var(&s.apiLogDirs, "api-log-dir", "directory of Anthropic API response dumps to include (repeatable; overrides CCUSAGE_API_LOG_DIR)")
+	fs.Var(&s.names, "source", "source to read: claude-code, codex, anthropic-api or all (repeatable or comma-separated; default claude-code plus any API dump directories)")
+	return s
 }
 
-// minimalRoots filters a set of directory paths to only the top-level roots,
-// removing any path that is a descendant of another path in the set.
-func minimalRoots(dirs map[string]bool) []string {
-	paths := make([]string, 0, len(dirs))
-	for d := range dirs {
-		paths = append(paths, d)
+// sources returns the usage sources to read: those named by --source, or
+// Claude Code and any API dump directories.
+func (s *sourceFlags) sources() source.Set {
+	var names []string
+	for _, v := range s.names {
+		names = append(names, strings.Split(v, ",")...)
 	}
-	sort.Strings(paths)
-
-	var roots []string
-	for _, p := range paths {
-		isChild := false
-		for _, root := range roots {
-			if strings.HasPrefix(p, root+string(filepath.Separator)) {
-				isChild = true
-				break
-			}
-		}
-		if !isChild {
-			roots = append(roots, p)
-		}
+	set, err := source.Select(names, s.configDirs, s.apiLogDirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: --source: %v\n", err)
+		os.Exit(1)
 	}
-	return roots
-}
-
-// EntryData stores parsed entry info for deduplication
-type EntryData struct {
-	Key                 string `json:"key"`
-	Date                string `json:"date"`
-	Model               string `json:"model"`
-	InputTokens         int    `json:"input_tokens"`
-	OutputTokens        int    `json:"output_tokens"`
-	CacheCreationTokens int    `json:"cache_creation_tokens"`
-	CacheWrite1hTokens  int    `json:"cache_write_1h_tokens"`
-	CacheReadTokens     int    `json:"cache_read_tokens"`
-	WebSearchRequests   int    `json:"web_search_requests"`
-}
-
-type FileStats struct {
-	LinesRead   int
-	LinesParsed int
-	EntriesNew  int
-}
-
-// Cache types
-const CacheVersion = 5
-
-var cacheMagic = [4]byte{'C', 'C', 'U', 'G'}
-
-type FileCacheEntry struct {
-	ModTime int64
-	Size    int64
-	Entries []EntryData
-}
-
-type CacheFile struct {
-	Version      int
-	Timezone     string
-	Files        map[string]*FileCacheEntry
-	Dirs         map[string]int64
-	LastFullWalk time.Time
+	return set
 }
 
-// Encoded types for string-interned binary cache
-type EncodedCache struct {
-	StringTable  []string
-	Files        map[string]*EncodedFileCacheEntry
-	Dirs         map[string]int64
-	LastFullWalk time.Time
+// addDedupFlag registers --dedup, which picks the copy counted for requests
+// logged more than once.
+func addDedupFlag(fs *flag.FlagSet) *string {
+	return fs.String("dedup", string(usage.DedupMaxTokens), "copy counted for requests logged more than once, across files or within one: first-seen, max-tokens, last-seen, message-id-only (within a file the default max-tokens now counts the largest copy, not the first)")
 }
 
-type EncodedFileCacheEntry struct {
-	ModTime int64
-	Size    int64
-	Entries []EncodedEntry
-}
-
-type EncodedEntry struct {
-	Key                 string
-	DateIdx             int
-	ModelIdx            int
-	InputTokens         int
-	OutputTokens        int
-	CacheCreationTokens int
-	CacheWrite1hTokens  int
-	CacheReadTokens     int
-	WebSearchRequests   int
-}
-
-type discoveryStats struct {
-	dirsChecked    int
-	dirsChanged    int
-	subtreesWalked int
-	filesFromCache int
-	fullWalk       bool
-}
-
-func getCacheDir() string {
-	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
-		return filepath.Join(dir, "ccusage")
+// dedupStrategy validates a --dedup value after the flags have been parsed.
+func dedupStrategy(name string) usage.Dedup {
+	d, err := usage.ParseDedup(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: --dedup must be one of: first-seen, max-tokens, last-seen, message-id-only\n")
+		os.Exit(1)
 	}
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".cache", "ccusage")
-}
-
-func getCachePath() string {
-	return filepath.Join(getCacheDir(), "cache.bin")
-}
-
-func getLegacyCachePath() string {
-	return filepath.Join(getCacheDir(), "cache.json")
+	return d
 }
 
-func getLocalTimezone() string {
-	return "UTC"
+// addCostModeFlag registers --cost-mode, which decides whether costs logged by
+// older Claude Code versions are used.
+func addCostModeFlag(fs *flag.FlagSet) *string {
+	return fs.String("cost-mode", string(usage.CostAuto), "auto (use logged costUSD when present), calculate (always price tokens) or display (price tokens and show the logged cost)")
 }
 
-// loadLegacyJSONCache tries to read the old JSON cache format and migrate it
-func loadLegacyJSONCache() *CacheFile {
-	data, err := os.ReadFile(getLegacyCachePath())
+// costMode validates a --cost-mode value after the flags have been parsed.
+func costMode(name string) usage.CostMode {
+	m, err := usage.ParseCostMode(name)
 	if err != nil {
-		return nil
-	}
-	type jsonFileCacheEntry struct {
-		ModTime int64        `json:"mtime"`
-		Size    int64        `json:"size"`
-		Entries []*EntryData `json:"entries"`
-	}
-	type jsonCacheFile struct {
-		Version      int                            `json:"version"`
-		Timezone     string                         `json:"timezone"`
-		Files        map[string]*jsonFileCacheEntry  `json:"files"`
-		Dirs         map[string]int64               `json:"dirs,omitempty"`
-		LastFullWalk time.Time                      `json:"last_full_walk,omitempty"`
-	}
-	var legacy jsonCacheFile
-	if json.Unmarshal(data, &legacy) != nil {
-		return nil
-	}
-	cache := &CacheFile{
-		Version:      legacy.Version,
-		Timezone:     legacy.Timezone,
-		Files:        make(map[string]*FileCacheEntry, len(legacy.Files)),
-		Dirs:         legacy.Dirs,
-		LastFullWalk: legacy.LastFullWalk,
-	}
-	for path, fe := range legacy.Files {
-		entries := make([]EntryData, len(fe.Entries))
-		for i, e := range fe.Entries {
-			entries[i] = *e
-		}
-		cache.Files[path] = &FileCacheEntry{
-			ModTime: fe.ModTime,
-			Size:    fe.Size,
-			Entries: entries,
-		}
+		fmt.Fprintf(os.Stderr, "error: --cost-mode must be one of: auto, calculate, display\n")
+		os.Exit(1)
 	}
-	return cache
+	return m
 }
 
-func loadCache() *CacheFile {
-	data, err := os.ReadFile(getCachePath())
-	if err != nil {
-		return loadLegacyJSONCache()
-	}
-
-	// Verify binary header: magic + version
-	if len(data) < 8 {
-		return loadLegacyJSONCache()
-	}
-	if data[0] != cacheMagic[0] || data[1] != cacheMagic[1] || data[2] != cacheMagic[2] || data[3] != cacheMagic[3] {
-		return loadLegacyJSONCache()
-	}
-	version := binary.LittleEndian.Uint32(data[4:8])
-	if version != CacheVersion {
-		return nil
-	}
-
-	// Decode gob payload
-	var encoded EncodedCache
-	if err := gob.NewDecoder(bytes.NewReader(data[8:])).Decode(&encoded); err != nil {
-		return nil
-	}
-
-	// De-intern strings
-	cache := &CacheFile{
-		Version:      int(version),
-		Timezone:     "",
-		Files:        make(map[string]*FileCacheEntry, len(encoded.Files)),
-		Dirs:         encoded.Dirs,
-		LastFullWalk: encoded.LastFullWalk,
-	}
-	if len(encoded.StringTable) > 0 {
-		cache.Timezone = encoded.StringTable[0]
-	}
-
-	for path, fe := range encoded.Files {
-		entries := make([]EntryData, len(fe.Entries))
-		for i, ee := range fe.Entries {
-			dateStr := ""
-			if ee.DateIdx >= 0 && ee.DateIdx < len(encoded.StringTable) {
-				dateStr = encoded.StringTable[ee.DateIdx]
-			}
-			modelStr := ""
-			if ee.ModelIdx >= 0 && ee.ModelIdx < len(encoded.StringTable) {
-				modelStr = encoded.StringTable[ee.ModelIdx]
-			}
-			entries[i] = EntryData{
-				Key:                 ee.Key,
-				Date:                dateStr,
-				Model:               modelStr,
-				InputTokens:         ee.InputTokens,
-				OutputTokens:        ee.OutputTokens,
-				CacheCreationTokens: ee.CacheCreationTokens,
-				CacheWrite1hTokens:  ee.CacheWrite1hTokens,
-				CacheReadTokens:     ee.CacheReadTokens,
-				WebSearchRequests:   ee.WebSearchRequests,
-			}
-		}
-		cache.Files[path] = &FileCacheEntry{
-			ModTime: fe.ModTime,
-			Size:    fe.Size,
-			Entries: entries,
-		}
-	}
-	return cache
+// commonFlags are the flags shared by report subcommands.
+type commonFlags struct {
+	days     *int
+	showAll  *bool
+	noCache  *bool
+	dedup    *string
+	costMode *string
+	*sourceFlags
+	inputs *inputFlags
 }
 
-func saveCache(cache *CacheFile) error {
-	dir := getCacheDir()
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	// Build string table for interning
-	stringIndex := make(map[string]int)
-	var stringTable []string
-	intern := func(s string) int {
-		if idx, ok := stringIndex[s]; ok {
-			return idx
-		}
-		idx := len(stringTable)
-		stringTable = append(stringTable, s)
-		stringIndex[s] = idx
-		return idx
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	return &commonFlags{
+		days:        fs.Int("days", 0, "number of days of history to show (default: month to date)"),
+		showAll:     fs.Bool("all", false, "show all history (overrides --days)"),
+		noCache:     fs.Bool("no-cache", false, "skip reading cache (still writes cache)"),
+		dedup:       addDedupFlag(fs),
+		costMode:    addCostModeFlag(fs),
+		sourceFlags: addSourceFlags(fs),
+		inputs:      addInputFlags(fs),
 	}
+}
 
-	// Timezone is always index 0
-	intern(cache.Timezone)
-
-	// Build encoded structure
-	encoded := EncodedCache{
-		Files:        make(map[string]*EncodedFileCacheEntry, len(cache.Files)),
-		Dirs:         cache.Dirs,
-		LastFullWalk: cache.LastFullWalk,
-	}
-	for path, fe := range cache.Files {
-		entries := make([]EncodedEntry, len(fe.Entries))
-		for i, e := range fe.Entries {
-			entries[i] = EncodedEntry{
-				Key:                 e.Key,
-				DateIdx:             intern(e.Date),
-				ModelIdx:            intern(e.Model),
-				InputTokens:         e.InputTokens,
-				OutputTokens:        e.OutputTokens,
-				CacheCreationTokens: e.CacheCreationTokens,
-				CacheWrite1hTokens:  e.CacheWrite1hTokens,
-				CacheReadTokens:     e.CacheReadTokens,
-				WebSearchRequests:   e.WebSearchRequests,
-			}
-		}
-		encoded.Files[path] = &EncodedFileCacheEntry{
-			ModTime: fe.ModTime,
-			Size:    fe.Size,
-			Entries: entries,
+// options validates the date range flags after fs has been parsed.
+func (c *commonFlags) options(fs *flag.FlagSet) reportOptions {
+	daysExplicit := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "days" {
+			daysExplicit = true
 		}
+	})
+	if *c.showAll && daysExplicit {
+		fmt.Fprintf(os.Stderr, "error: --all and --days are mutually exclusive\n")
+		os.Exit(1)
 	}
-	encoded.StringTable = stringTable
-
-	// Encode: magic + version + gob payload
-	var buf bytes.Buffer
-	buf.Write(cacheMagic[:])
-	var versionBytes [4]byte
-	binary.LittleEndian.PutUint32(versionBytes[:], uint32(CacheVersion))
-	buf.Write(versionBytes[:])
-
-	if err := gob.NewEncoder(&buf).Encode(encoded); err != nil {
-		return err
+	if daysExplicit && *c.days <= 0 {
+		fmt.Fprintf(os.Stderr, "error: --days must be positive\n")
+		os.Exit(1)
 	}
-
-	tmpPath := getCachePath() + ".tmp"
-	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
-		return err
+	return reportOptions{
+		showAll:      *c.showAll,
+		days:         *c.days,
+		daysExplicit: daysExplicit,
+		dedup:        dedupStrategy(*c.dedup),
+		costMode:     costMode(*c.costMode),
 	}
-
-	// Remove legacy JSON cache if it exists
-	os.Remove(getLegacyCachePath())
-
-	return os.Rename(tmpPath, getCachePath())
 }
 
-// processFileForCache parses a JSONL file and returns entries keyed by dedup key
-func processFileForCache(path string) (map[string]EntryData, FileStats) {
-	entries := make(map[string]EntryData)
-	var stats FileStats
-
-	f, err := os.Open(path)
-	if err != nil {
-		return entries, stats
-	}
-	defer func() { _ = f.Close() }()
-
-	scanner := bufio.NewScanner(f)
-	buf := make([]byte, 0, 1024*1024)
-	scanner.Buffer(buf, 10*1024*1024)
-
-	for scanner.Scan() {
-		stats.LinesRead++
-		var entry LogEntry
-		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
-			continue
-		}
-		stats.LinesParsed++
-		if entry.Timestamp == "" || (entry.Message.Usage.InputTokens == 0 && entry.Message.Usage.OutputTokens == 0) {
-			continue
-		}
-		if entry.Message.ID == "" || entry.RequestID == "" {
-			continue
-		}
-
-		key := entry.Message.ID + ":" + entry.RequestID
-		t, err := time.Parse(time.RFC3339, entry.Timestamp)
+// loadEntries refreshes the cache and returns the deduplicated entries in the
+// report range, saving the cache if it changed.
+func (c *commonFlags) loadEntries(opts reportOptions) map[string]*usage.Entry {
+	if c.inputs.enabled() {
+		cache, err := c.inputs.load(opts.dedup)
 		if err != nil {
-			continue
-		}
-		date := t.UTC().Format("2006-01-02")
-		model := entry.Message.Model
-		if model == "" {
-			model = "unknown"
-		}
-
-		// Fast mode detection: append :fast suffix for separate pricing
-		if entry.Message.Usage.Speed == "fast" {
-			model = model + ":fast"
-		}
-
-		// Cache write breakdown: split 5m vs 1h when breakdown is available
-		cacheWrite5m := entry.Message.Usage.CacheCreationTokens
-		cacheWrite1h := 0
-		if cc := entry.Message.Usage.CacheCreation; cc.Ephemeral5m+cc.Ephemeral1h > 0 {
-			cacheWrite5m = cc.Ephemeral5m
-			cacheWrite1h = cc.Ephemeral1h
-		}
-
-		if _, exists := entries[key]; !exists {
-			entries[key] = EntryData{
-				Key:                 key,
-				Date:                date,
-				Model:               model,
-				InputTokens:         entry.Message.Usage.InputTokens,
-				OutputTokens:        entry.Message.Usage.OutputTokens,
-				CacheCreationTokens: cacheWrite5m,
-				CacheWrite1hTokens:  cacheWrite1h,
-				CacheReadTokens:     entry.Message.Usage.CacheReadTokens,
-				WebSearchRequests:   entry.Message.Usage.ServerToolUse.WebSearchRequests,
-			}
-			stats.EntriesNew++
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
+		var dedupStats usage.MergeStats
+		return usage.Since(cache.Dedup(&dedupStats), opts.cutoff())
 	}
-	return entries, stats
-}
-
-type cacheStats struct {
-	hits       int
-	misses     int
-	totalLines int
-	totalNew   int
-	conflicts  int
-}
-
-func entryTotalTokens(e *EntryData) int {
-	return e.InputTokens + e.OutputTokens + e.CacheCreationTokens + e.CacheWrite1hTokens + e.CacheReadTokens
-}
-
-// processWithCacheLoaded processes files using a pre-loaded cache.
-func processWithCacheLoaded(files []string, cache *CacheFile, cacheValid bool) (map[string]*EntryData, cacheStats, bool) {
-	var stats cacheStats
-	dirty := false
-
-	if !cacheValid {
-		dirty = true
-	}
-
-	// Pre-size allEntries from cache data
-	expectedCount := 0
-	for _, fc := range cache.Files {
-		expectedCount += len(fc.Entries)
-	}
-	allEntries := make(map[string]*EntryData, expectedCount)
-
-	existingFiles := make(map[string]bool)
-
-	// Phase 1: Sequential scan — handle cache hits, collect misses
-	type cacheMiss struct {
-		path  string
-		mtime int64
-		size  int64
+	ds, err := ccusage.Load(context.Background(), ccusage.Options{Sources: c.sources(), NoCache: *c.noCache, Dedup: opts.dedup, CostMode: opts.costMode})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
 	}
-	var misses []cacheMiss
+	return usage.Since(ds.Entries(), opts.cutoff())
+}
 
-	for _, path := range files {
-		existingFiles[path] = true
-		fi, err := os.Stat(path)
+// loadCache refreshes and returns the cache, or the in-memory cache of the
+// inputs, for reports that need every copy of a request rather than the
+// deduplicated entries.
+func (c *commonFlags) loadCache(opts reportOptions) *store.Cache {
+	if c.inputs.enabled() {
+		cache, err := c.inputs.load(opts.dedup)
 		if err != nil {
-			continue
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
-		mtime := fi.ModTime().UnixNano()
-		size := fi.Size()
-
-		cached, ok := cache.Files[path]
-		if cacheValid && ok && cached.ModTime == mtime && cached.Size == size {
-			stats.hits++
-			for i := range cached.Entries {
-				e := &cached.Entries[i]
-				if existing, ok := allEntries[e.Key]; ok {
-					if entryTotalTokens(e) > entryTotalTokens(existing) {
-						allEntries[e.Key] = e
-						stats.conflicts++
-					}
-				} else {
-					allEntries[e.Key] = e
-					stats.totalNew++
-				}
-			}
-		} else {
-			stats.misses++
-			misses = append(misses, cacheMiss{path: path, mtime: mtime, size: size})
-		}
-	}
-
-	if len(misses) > 0 {
-		dirty = true
+		return cache
 	}
-
-	// Phase 2: Concurrent parsing of cache misses
-	type fileResult struct {
-		path    string
-		mtime   int64
-		size    int64
-		entries []EntryData
-		stats   FileStats
+	cache, cacheValid := store.Open(*c.noCache, false)
+	cache.SetDedup(opts.dedup)
+	if refreshCache(cache, c.sources(), cacheValid) {
+		_ = cache.Save()
 	}
-	results := make([]fileResult, len(misses))
-
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, runtime.NumCPU())
+	return cache
+}
 
-	for i, miss := range misses {
-		wg.Add(1)
-		go func(i int, miss cacheMiss) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+// refreshCache brings the cache up to date with the logs of every source and
+// reports whether it changed and needs saving.
+func refreshCache(cache *store.Cache, sources source.Set, cacheValid bool) bool {
+	// The background context is never cancelled, so Refresh cannot fail
+	changed, _ := cache.Refresh(context.Background(), sources, cacheValid)
+	return changed
+}
 
-			entries, fStats := processFileForCache(miss.path)
-			entrySlice := make([]EntryData, 0, len(entries))
-			for _, e := range entries {
-				entrySlice = append(entrySlice, e)
-			}
-			results[i] = fileResult{
-				path:    miss.path,
-				mtime:   miss.mtime,
-				size:    miss.size,
-				entries: entrySlice,
-				stats:   fStats,
-			}
-		}(i, miss)
-	}
-	wg.Wait()
+// reportOptions controls the date range and grouping of the printed report.
+type reportOptions struct {
+	showAll      bool
+	days         int
+	daysExplicit bool
+	groupBy      string
+	project      string
+	format       string
+	chart        string
+	dedup        usage.Dedup
+	costMode     usage.CostMode
+}
 
-	// Phase 3: Sequential merge — dedup entries, update cache
-	for ri := range results {
-		r := &results[ri]
-		stats.totalLines += r.stats.LinesRead
-		for i := range r.entries {
-			e := &r.entries[i]
-			if existing, ok := allEntries[e.Key]; ok {
-				if entryTotalTokens(e) > entryTotalTokens(existing) {
-					allEntries[e.Key] = e
-					stats.conflicts++
-				}
-			} else {
-				allEntries[e.Key] = e
-				stats.totalNew++
-			}
-		}
-		cache.Files[r.path] = &FileCacheEntry{
-			ModTime: r.mtime,
-			Size:    r.size,
-			Entries: r.entries,
-		}
+// cutoff returns the earliest date included in the report, or "" for all history.
+func (o reportOptions) cutoff() string {
+	if o.showAll {
+		return ""
 	}
-
-	// Cleanup deleted files
-	for path := range cache.Files {
-		if !existingFiles[path] {
-			dirty = true
-			delete(cache.Files, path)
-		}
+	now := time.Now().UTC()
+	if o.daysExplicit {
+		return now.AddDate(0, 0, -(o.days - 1)).Format("2006-01-02")
 	}
-
-	return allEntries, stats, dirty
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
 }
 
-func aggregateUsage(entries map[string]*EntryData) map[string]*DayUsage {
-	dayUsage := make(map[string]*DayUsage)
-	for _, e := range entries {
-		if dayUsage[e.Date] == nil {
-			dayUsage[e.Date] = &DayUsage{Models: make(map[string]*Usage)}
-		}
-		if dayUsage[e.Date].Models[e.Model] == nil {
-			dayUsage[e.Date].Models[e.Model] = &Usage{}
-		}
-		dayUsage[e.Date].Models[e.Model].Input += e.InputTokens
-		dayUsage[e.Date].Models[e.Model].Output += e.OutputTokens
-		dayUsage[e.Date].Models[e.Model].CacheWrite += e.CacheCreationTokens
-		dayUsage[e.Date].Models[e.Model].CacheWrite1h += e.CacheWrite1hTokens
-		dayUsage[e.Date].Models[e.Model].CacheRead += e.CacheReadTokens
-		dayUsage[e.Date].Models[e.Model].WebSearchRequests += e.WebSearchRequests
+// describe returns a human-readable name for the report's date range.
+func (o reportOptions) describe() string {
+	switch {
+	case o.showAll:
+		return "All history"
+	case o.daysExplicit && o.days == 1:
+		return "Today"
+	case o.daysExplicit:
+		return fmt.Sprintf("Last %d days", o.days)
+	default:
+		return "Month to date"
 	}
-	return dayUsage
 }
 
-func calculateCost(day *DayUsage, pricing map[string]ModelPricing) float64 {
-	var total float64
-	for model, usage := range day.Models {
-		p := pricing[model]
-		if p.Input == 0 && p.Output == 0 {
-			p = pricing["default"]
-		}
-
-		total += (float64(usage.Input)*p.Input +
-			float64(usage.Output)*p.Output +
-			float64(usage.CacheWrite)*p.CacheWrite +
-			float64(usage.CacheWrite1h)*p.CacheWrite1h +
-			float64(usage.CacheRead)*p.CacheRead) / 1_000_000
-		// Web search: $10 per 1,000 requests
-		total += float64(usage.WebSearchRequests) * 0.01
+// printReport prints the usage table for the cached data according to opts.
+func printReport(cache *store.Cache, opts reportOptions) {
+	var write func(io.Writer, *store.Cache, reportOptions) error
+	switch opts.format {
+	case "html":
+		write = writeHTMLReport
+	case "markdown":
+		write = writeMarkdownReport
 	}
-	return total
-}
-
-func calculateCostAllRegular(day *DayUsage, pricing map[string]ModelPricing) float64 {
-	var total float64
-	for model, usage := range day.Models {
-		baseModel := strings.TrimSuffix(model, ":fast")
-		p := pricing[baseModel]
-		if p.Input == 0 && p.Output == 0 {
-			p = pricing["default"]
+	if write != nil {
+		if err := write(os.Stdout, cache, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
-		total += (float64(usage.Input)*p.Input +
-			float64(usage.Output)*p.Output +
-			float64(usage.CacheWrite)*p.CacheWrite +
-			float64(usage.CacheWrite1h)*p.CacheWrite1h +
-			float64(usage.CacheRead)*p.CacheRead) / 1_000_000
-		total += float64(usage.WebSearchRequests) * 0.01
+		return
 	}
-	return total
-}
 
-func calculateCostAllFast(day *DayUsage, pricing map[string]ModelPricing) float64 {
-	var total float64
-	for model, usage := range day.Models {
-		var p ModelPricing
-		if strings.HasSuffix(model, ":fast") {
-			p = pricing[model]
-		} else {
-			if fp, ok := pricing[model+":fast"]; ok {
-				p = fp
-			} else {
-				p = pricing[model]
+	cutoff := opts.cutoff()
+	switch opts.groupBy {
+	case "root":
+		// Non-date groupings need the deduplicated entries rather than the rollups
+		var dedupStats usage.MergeStats
+		entries := usage.Since(cache.Dedup(&dedupStats), cutoff)
+		render.Table(os.Stdout, "Root", usage.AggregateBy(entries, func(e *usage.Entry) string { return e.Root }), pricing.Default, opts.costMode)
+	case "source":
+		var dedupStats usage.MergeStats
+		entries := usage.Since(cache.Dedup(&dedupStats), cutoff)
+		render.Table(os.Stdout, "Source", usage.AggregateBy(entries, func(e *usage.Entry) string { return e.Source }), pricing.Default, opts.costMode)
+	case "branch":
+		var dedupStats usage.MergeStats
+		entries := usage.Since(cache.Dedup(&dedupStats), cutoff)
+		if opts.project != "" {
+			entries = filterProject(entries, opts.project)
+			if len(entries) == 0 {
+				fmt.Fprintf(os.Stderr, "No usage found for project %s\n", opts.project)
+				return
 			}
 		}
-		if p.Input == 0 && p.Output == 0 {
-			p = pricing["default"]
+		render.Table(os.Stdout, "Branch", usage.AggregateBy(entries, func(e *usage.Entry) string {
+			if e.Branch == "" {
+				return "(none)"
+			}
+			return e.Branch
+		}), pricing.Default, opts.costMode)
+	default:
+		dayUsage := usage.RollupDays(cache.Rollups, cutoff)
+		dailyCosts := render.Table(os.Stdout, "Date", dayUsage, pricing.Default, opts.costMode)
+		if opts.chart != "" {
+			printChart(opts.chart, dayUsage, dailyCosts, opts.costMode)
 		}
-		total += (float64(usage.Input)*p.Input +
-			float64(usage.Output)*p.Output +
-			float64(usage.CacheWrite)*p.CacheWrite +
-			float64(usage.CacheWrite1h)*p.CacheWrite1h +
-			float64(usage.CacheRead)*p.CacheRead) / 1_000_000
-		total += float64(usage.WebSearchRequests) * 0.01
-	}
-	return total
-}
-
-func sumUsage(day *DayUsage) (input, output, cacheWrite, cacheRead int) {
-	for _, u := range day.Models {
-		input += u.Input
-		output += u.Output
-		cacheWrite += u.CacheWrite + u.CacheWrite1h
-		cacheRead += u.CacheRead
-	}
-	return
-}
-
-func formatNumber(n int) string {
-	s := fmt.Sprintf("%d", n)
-	if len(s) <= 3 {
-		return s
-	}
-	var result []byte
-	for i, c := range s {
-		if i > 0 && (len(s)-i)%3 == 0 {
-			result = append(result, ',')
+		if !opts.showAll && len(dailyCosts) >= 2 {
+			render.WriteProjections(os.Stdout, dailyCosts)
 		}
-		result = append(result, byte(c))
-	}
-	return string(result)
-}
-
-func formatDollars(v float64) string {
-	cents := int(math.Round(v * 100))
-	whole := cents / 100
-	frac := cents % 100
-	if frac < 0 {
-		frac = -frac
-	}
-	return fmt.Sprintf("$%s.%02d", formatNumber(whole), frac)
-}
-
-func printTable(dayUsage map[string]*DayUsage, pricing map[string]ModelPricing) []float64 {
-	var dates []string
-	for d := range dayUsage {
-		dates = append(dates, d)
-	}
-	sort.Strings(dates)
-
-	const width = 126
-	fmt.Printf("%-15s %17s %17s %17s %17s %12s %12s %12s\n",
-		"Date", "Input", "Output", "CacheWrite", "CacheRead", "Cost", "AllRegular", "AllFast")
-	fmt.Println(strings.Repeat("-", width))
-
-	var totalInput, totalOutput, totalCacheWrite, totalCacheRead int
-	var totalCost, totalCostRegular, totalCostFast float64
-	var dailyCosts []float64
-	for _, date := range dates {
-		day := dayUsage[date]
-		input, output, cacheWrite, cacheRead := sumUsage(day)
-		cost := calculateCost(day, pricing)
-		costRegular := calculateCostAllRegular(day, pricing)
-		costFast := calculateCostAllFast(day, pricing)
-		totalInput += input
-		totalOutput += output
-		totalCacheWrite += cacheWrite
-		totalCacheRead += cacheRead
-		totalCost += cost
-		totalCostRegular += costRegular
-		totalCostFast += costFast
-		dailyCosts = append(dailyCosts, cost)
-		fmt.Printf("%-15s %17s %17s %17s %17s %12s %12s %12s\n",
-			date,
-			formatNumber(input),
-			formatNumber(output),
-			formatNumber(cacheWrite),
-			formatNumber(cacheRead),
-			formatDollars(cost),
-			formatDollars(costRegular),
-			formatDollars(costFast))
-	}
-
-	fmt.Println(strings.Repeat("-", width))
-	fmt.Printf("%-15s %17s %17s %17s %17s %12s %12s %12s\n",
-		"Total",
-		formatNumber(totalInput),
-		formatNumber(totalOutput),
-		formatNumber(totalCacheWrite),
-		formatNumber(totalCacheRead),
-		formatDollars(totalCost),
-		formatDollars(totalCostRegular),
-		formatDollars(totalCostFast))
-	return dailyCosts
-}
-
-func percentile(sorted []float64, p float64) float64 {
-	n := len(sorted)
-	idx := int(math.Ceil(p/100.0*float64(n))) - 1
-	if idx < 0 {
-		idx = 0
 	}
-	if idx >= n {
-		idx = n - 1
-	}
-	return sorted[idx]
 }
 
-func printProjections(dailyCosts []float64) {
-	if len(dailyCosts) < 2 {
-		return
-	}
-
-	sorted := make([]float64, len(dailyCosts))
-	copy(sorted, dailyCosts)
-	sort.Float64s(sorted)
-
-	var sum float64
-	for _, c := range dailyCosts {
-		sum += c
-	}
-	mean := sum / float64(len(dailyCosts))
-	p50 := percentile(sorted, 50)
-	p75 := percentile(sorted, 75)
-	p99 := percentile(sorted, 99)
-
-	now := time.Now().UTC()
-	daysInMonth := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
-
-	fmt.Printf("\nProjections (MTD, %d days sampled)\n", len(dailyCosts))
-	fmt.Printf("%-10s %12s %12s %12s\n", "", "Daily", "Monthly", "Yearly")
-	for _, stat := range []struct {
-		name string
-		val  float64
-	}{
-		{"Mean", mean},
-		{"p50", p50},
-		{"p75", p75},
-		{"p99", p99},
-	} {
-		monthly := stat.val * float64(daysInMonth)
-		yearly := stat.val * 365
-		fmt.Printf("  %-8s %12s %12s %12s\n",
-			stat.name,
-			formatDollars(stat.val),
-			formatDollars(monthly),
-			formatDollars(yearly))
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tui":
+			runTUI(os.Args[2:])
+			return
+		case "statusline":
+			runStatusline(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "heatmap":
+			runHeatmap(os.Args[2:])
+			return
+		case "subagents":
+			runSubagents(os.Args[2:])
+			return
+		case "versions":
+			runVersions(os.Args[2:])
+			return
+		case "tools":
+			runTools(os.Args[2:])
+			return
+		case "conflicts":
+			runConflicts(os.Args[2:])
+			return
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "reconcile":
+			runReconcile(os.Args[2:])
+			return
+		}
 	}
-}
 
-func main() {
 	verbose := flag.Bool("v", false, "verbose timing output")
-	noCache := flag.Bool("no-cache", false, "skip reading cache (still writes cache)")
 	clearCache := flag.Bool("clear-cache", false, "delete cache and rebuild")
-	days := flag.Int("days", 0, "number of days of history to show (default: month to date)")
-	showAll := flag.Bool("all", false, "show all history (overrides --days)")
-	flag.Parse()
-
-	// Validate mutually exclusive flags
-	daysExplicit := false
-	flag.Visit(func(f *flag.Flag) {
-		if f.Name == "days" {
-			daysExplicit = true
-		}
-	})
-	if *showAll && daysExplicit {
-		fmt.Fprintf(os.Stderr, "error: --all and --days are mutually exclusive\n")
+	groupBy := flag.String("group-by", "date", "group rows by: date, root, branch, source")
+	project := flag.String("project", "", "limit --group-by branch to one project (log directory name or working directory)")
+	watch := flag.Bool("watch", false, "keep running and redraw as new usage is logged")
+	format := flag.String("format", "table", "output format: table, html, markdown")
+	common := addCommonFlags(flag.CommandLine)
+	var chart chartFlag
+	flag.Var(&chart, "chart", "render charts below the table: cost (default) or tokens")
+	_ = flag.CommandLine.Parse(chartArgs(os.Args[1:]))
+
+	opts := common.options(flag.CommandLine)
+	if *groupBy != "date" && *groupBy != "root" && *groupBy != "branch" && *groupBy != "source" {
+		fmt.Fprintf(os.Stderr, "error: --group-by must be one of: date, root, branch, source\n")
 		os.Exit(1)
 	}
-	if daysExplicit && *days <= 0 {
-		fmt.Fprintf(os.Stderr, "error: --days must be positive\n")
+	if *project != "" && *groupBy != "branch" {
+		fmt.Fprintf(os.Stderr, "error: --project requires --group-by branch\n")
 		os.Exit(1)
 	}
-
-	totalStart := time.Now()
-
-	// Load cache early so findJSONLFiles can use the directory manifest
-	if *clearCache {
-		os.Remove(getCachePath())
-		os.Remove(getLegacyCachePath())
+	if *format != "table" && *format != "html" && *format != "markdown" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, html, markdown\n")
+		os.Exit(1)
 	}
-	var cache *CacheFile
-	if !*noCache && !*clearCache {
-		cache = loadCache()
+	if *format != "table" && (*watch || *groupBy != "date" || chart != "") {
+		fmt.Fprintf(os.Stderr, "error: --watch, --group-by and --chart require --format table\n")
+		os.Exit(1)
 	}
-	cacheValid := cache != nil &&
-		cache.Version == CacheVersion &&
-		cache.Timezone == getLocalTimezone()
-	if !cacheValid {
-		cache = &CacheFile{
-			Version:  CacheVersion,
-			Timezone: getLocalTimezone(),
-			Files:    make(map[string]*FileCacheEntry),
+	opts.groupBy = *groupBy
+	opts.project = *project
+	opts.format = *format
+	opts.chart = string(chart)
+
+	if common.inputs.enabled() {
+		if *watch {
+			fmt.Fprintf(os.Stderr, "error: --watch cannot be combined with --input or --stdin\n")
+			os.Exit(1)
+		}
+		cache, err := common.inputs.load(opts.dedup)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
+		printReport(cache, opts)
+		return
 	}
 
+	totalStart := time.Now()
+
+	// Load cache early so discovery can use the directory manifest
+	cache, cacheValid := store.Open(*common.noCache, *clearCache)
+	cache.SetDedup(opts.dedup)
+
 	// Phase 1: Find files (uses directory manifest on warm runs)
 	start := time.Now()
-	configDir := getConfigDir()
-	files, dStats := findJSONLFiles(filepath.Join(configDir, "projects"), cache)
+	sources := common.sources()
+	files, dStats := discovery.Find(sources.Dirs(), &cache.Manifest, maps.Keys(cache.Files))
 	findDuration := time.Since(start)
 
 	// Phase 2: Process files (with caching)
 	start = time.Now()
-	entries, cStats, dirty := processWithCacheLoaded(files, cache, cacheValid)
+	changes, cStats, err := cache.Update(context.Background(), sources, files, cacheValid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 	processDuration := time.Since(start)
 
-	// Phase 3: Aggregate
+	// Phase 3: Aggregate (only days touched by changed files are recomputed)
 	start = time.Now()
-	dayUsage := aggregateUsage(entries)
-	aggregateDuration := time.Since(start)
+	cache.RefreshRollups(changes, &cStats)
+	dirty := changes.Dirty()
 
-	// Filter by date range
-	if !*showAll {
-		var cutoff string
-		now := time.Now().UTC()
-		if daysExplicit {
-			cutoff = now.AddDate(0, 0, -(*days - 1)).Format("2006-01-02")
-		} else {
-			cutoff = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
-		}
-		for date := range dayUsage {
-			if date < cutoff {
-				delete(dayUsage, date)
-			}
-		}
-	}
+	aggregateDuration := time.Since(start)
 
 	// Phase 4: Print table
 	start = time.Now()
-	dailyCosts := printTable(dayUsage, modelPricing)
-	if !*showAll && len(dailyCosts) >= 2 {
-		printProjections(dailyCosts)
+	if !*watch {
+		printReport(cache, opts)
 	}
 	printDuration := time.Since(start)
 
 	if *verbose {
 		fmt.Fprintf(os.Stderr, "\n--- Timing ---\n")
-		if dStats.fullWalk {
+		fmt.Fprintf(os.Stderr, "Sources:        %s\n", strings.Join(sources.Names(), ", "))
+		fmt.Fprintf(os.Stderr, "Log dirs:       %s\n", strings.Join(sources.Dirs(), ", "))
+		if dStats.FullWalk {
 			fmt.Fprintf(os.Stderr, "Find files:     %v (%d files, full walk, %d dirs)\n",
-				findDuration, len(files), dStats.dirsChecked)
+				findDuration, len(files), dStats.DirsChecked)
 		} else {
 			fmt.Fprintf(os.Stderr, "Find files:     %v (%d files, %d dirs checked, %d changed, %d subtrees walked, %d from cache)\n",
-				findDuration, len(files), dStats.dirsChecked, dStats.dirsChanged, dStats.subtreesWalked, dStats.filesFromCache)
+				findDuration, len(files), dStats.DirsChecked, dStats.DirsChanged, dStats.SubtreesWalked, dStats.FilesFromCache)
 		}
-		fmt.Fprintf(os.Stderr, "Process files:  %v (cache: %d hits, %d misses, %d lines parsed, %d unique, %d conflicts)\n",
-			processDuration, cStats.hits, cStats.misses, cStats.totalLines, cStats.totalNew, cStats.conflicts)
-		fmt.Fprintf(os.Stderr, "Aggregate:      %v\n", aggregateDuration)
-		if *showAll {
+		fmt.Fprintf(os.Stderr, "Process files:  %v (cache: %d hits, %d misses, %d lines parsed)\n",
+			processDuration, cStats.Hits, cStats.Misses, cStats.Lines)
+		fmt.Fprintf(os.Stderr, "Aggregate:      %v (%d days rebuilt, %d keys merged; %d requests, %d logged more than once)\n",
+			aggregateDuration, cStats.DaysRebuilt, cStats.KeysMerged, cStats.Requests, cStats.Duplicated)
+		if opts.showAll {
 			fmt.Fprintf(os.Stderr, "Date filter:    all dates\n")
-		} else if daysExplicit {
-			fmt.Fprintf(os.Stderr, "Date filter:    last %d days\n", *days)
+		} else if opts.daysExplicit {
+			fmt.Fprintf(os.Stderr, "Date filter:    last %d days\n", opts.days)
 		} else {
 			fmt.Fprintf(os.Stderr, "Date filter:    month to date\n")
 		}
@@ -1054,7 +444,11 @@ func main() {
 	}
 
 	// Save cache after output so the user sees results immediately
-	if dirty || dStats.fullWalk || dStats.dirsChanged > 0 {
-		_ = saveCache(cache)
+	if dirty || dStats.FullWalk || dStats.DirsChanged > 0 {
+		_ = cache.Save()
+	}
+
+	if *watch {
+		watchLoop(cache, sources, opts)
 	}
 }
@@ -0,0 +1,350 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	errSyntax = errors.New("invalid JSON")
+	// errFallback means the fast path met something it does not decode exactly
+	// like encoding/json, so the line is decoded in full instead.
+	errFallback = errors.New("not handled by fast decode")
+)
+
+// Field names of LogEntry and its nested structs. Keys matching one of these
+// only case-insensitively are left to encoding/json, which accepts them.
+var (
+	entryFields         = []string{"timestamp", "requestId", "gitBranch", "cwd", "sessionId", "agentId", "isSidechain", "version", "costUSD", "message"}
+	messageFields       = []string{"id", "model", "content", "usage"}
+	usageFields         = []string{"input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "cache_creation", "service_tier", "speed", "server_tool_use"}
+	cacheCreationFields = []string{"ephemeral_5m_input_tokens", "ephemeral_1h_input_tokens"}
+	serverToolUseFields = []string{"web_search_requests"}
+)
+
+// decodeEntry decodes a transcript line into e with the same result and
+// failures as json.Unmarshal. Most of a line is message content that Parse
+// never looks at, so rather than decoding it all the line is validated and
+// then skimmed for the few fields of LogEntry, skipping everything else
+// without allocating. Values repeated on every line of a file, like the cwd or
+// model, are shared through strs. e.Message.Content aliases line.
+func decodeEntry(line []byte, e *LogEntry, strs map[string]string) error {
+	if !json.Valid(line) {
+		return errSyntax
+	}
+	s := lineScanner{b: line, strs: strs}
+	if err := s.entry(e); err != errFallback {
+		return err
+	}
+	// Decode into a fresh entry so e itself never escapes to the heap
+	var full LogEntry
+	err := json.Unmarshal(line, &full)
+	*e = full
+	return err
+}
+
+// lineScanner walks a line already known to be valid JSON, so it needs no
+// error checks of its own.
+type lineScanner struct {
+	b    []byte
+	i    int
+	strs map[string]string
+}
+
+func (s *lineScanner) entry(e *LogEntry) error {
+	return s.object(entryFields, func(key []byte) error {
+		switch string(key) {
+		case "timestamp":
+			return s.string(&e.Timestamp)
+		case "requestId":
+			return s.string(&e.RequestID)
+		case "gitBranch":
+			return s.shared(&e.GitBranch)
+		case "cwd":
+			return s.shared(&e.Cwd)
+		case "sessionId":
+			return s.shared(&e.SessionID)
+		case "agentId":
+			return s.shared(&e.AgentID)
+		case "isSidechain":
+			return s.bool(&e.IsSidechain)
+		case "version":
+			return s.shared(&e.Version)
+		case "costUSD":
+			return s.float(&e.CostUSD)
+		case "message":
+			return s.message(e)
+		}
+		s.value()
+		return nil
+	})
+}
+
+func (s *lineScanner) message(e *LogEntry) error {
+	m := &e.Message
+	return s.object(messageFields, func(key []byte) error {
+		switch string(key) {
+		case "id":
+			return s.string(&m.ID)
+		case "model":
+			return s.shared(&m.Model)
+		case "content":
+			m.Content = s.value()
+			return nil
+		case "usage":
+			return s.usage(e)
+		}
+		s.value()
+		return nil
+	})
+}
+
+func (s *lineScanner) usage(e *LogEntry) error {
+	u := &e.Message.Usage
+	return s.object(usageFields, func(key []byte) error {
+		switch string(key) {
+		case "input_tokens":
+			return s.int(&u.InputTokens)
+		case "output_tokens":
+			return s.int(&u.OutputTokens)
+		case "cache_creation_input_tokens":
+			return s.int(&u.CacheCreationTokens)
+		case "cache_read_input_tokens":
+			return s.int(&u.CacheReadTokens)
+		case "service_tier":
+			return s.shared(&u.ServiceTier)
+		case "speed":
+			return s.shared(&u.Speed)
+		case "cache_creation":
+			return s.object(cacheCreationFields, func(key []byte) error {
+				switch string(key) {
+				case "ephemeral_5m_input_tokens":
+					return s.int(&u.CacheCreation.Ephemeral5m)
+				case "ephemeral_1h_input_tokens":
+					return s.int(&u.CacheCreation.Ephemeral1h)
+				}
+				s.value()
+				return nil
+			})
+		case "server_tool_use":
+			return s.object(serverToolUseFields, func(key []byte) error {
+				if string(key) == "web_search_requests" {
+					return s.int(&u.ServerToolUse.WebSearchRequests)
+				}
+				s.value()
+				return nil
+			})
+		}
+		s.value()
+		return nil
+	})
+}
+
+func (s *lineScanner) skipSpace() {
+	for s.i < len(s.b) {
+		switch s.b[s.i] {
+		case ' ', '\t', '\n', '\r':
+			s.i++
+		default:
+			return
+		}
+	}
+}
+
+// value skips the next value and returns its raw bytes.
+func (s *lineScanner) value() []byte {
+	s.skipSpace()
+	start := s.i
+	switch s.b[s.i] {
+	case '"':
+		s.i++
+		for {
+			s.i += bytes.IndexByte(s.b[s.i:], '"') + 1
+			// The quote closes the string unless escaped by an odd number of
+			// backslashes
+			n := 0
+			for s.b[s.i-2-n] == '\\' {
+				n++
+			}
+			if n%2 == 0 {
+				break
+			}
+		}
+	case '{', '[':
+		depth := 0
+		for {
+			switch s.b[s.i] {
+			case '"':
+				s.value()
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			s.i++
+			if depth == 0 {
+				break
+			}
+		}
+	default:
+		for s.i < len(s.b) {
+			switch s.b[s.i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return s.b[start:s.i]
+			}
+			s.i++
+		}
+	}
+	return s.b[start:s.i]
+}
+
+// object calls member for each key of the object at the current position,
+// which must consume the key's value. A null leaves the target untouched, as
+// with encoding/json; anything else but an object falls back. Keys that
+// encoding/json might match differently, i.e. escaped, non-ASCII or matching
+// one of fields only case-insensitively, also fall back.
+func (s *lineScanner) object(fields []string, member func(key []byte) error) error {
+	s.skipSpace()
+	switch s.b[s.i] {
+	case 'n':
+		s.value()
+		return nil
+	case '{':
+		s.i++
+	default:
+		return errFallback
+	}
+	for {
+		s.skipSpace()
+		switch s.b[s.i] {
+		case '}':
+			s.i++
+			return nil
+		case ',':
+			s.i++
+		}
+		key := s.value()
+		key = key[1 : len(key)-1]
+		s.skipSpace()
+		s.i++ // ':'
+		if !plainKey(key, fields) {
+			return errFallback
+		}
+		if err := member(key); err != nil {
+			return err
+		}
+	}
+}
+
+func plainKey(key []byte, fields []string) bool {
+	for _, c := range key {
+		if c == '\\' || c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	for _, f := range fields {
+		if len(key) == len(f) && string(key) != f && bytes.EqualFold(key, []byte(f)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *lineScanner) string(dst *string) error {
+	return s.decodeString(dst, false)
+}
+
+// shared is string for values repeated across lines, which are allocated once.
+func (s *lineScanner) shared(dst *string) error {
+	return s.decodeString(dst, true)
+}
+
+func (s *lineScanner) decodeString(dst *string, shared bool) error {
+	v := s.value()
+	switch v[0] {
+	case 'n':
+		return nil
+	case '"':
+	default:
+		return errFallback
+	}
+	raw := v[1 : len(v)-1]
+	if bytes.IndexByte(raw, '\\') >= 0 || !utf8.Valid(raw) {
+		// Escapes and invalid UTF-8 are rare; let encoding/json resolve them
+		var str string
+		err := json.Unmarshal(v, &str)
+		*dst = str
+		return err
+	}
+	if !shared || s.strs == nil {
+		*dst = string(raw)
+		return nil
+	}
+	str, ok := s.strs[string(raw)]
+	if !ok {
+		str = string(raw)
+		s.strs[str] = str
+	}
+	*dst = str
+	return nil
+}
+
+func (s *lineScanner) int(dst *int) error {
+	v := s.value()
+	if v[0] == 'n' {
+		return nil
+	}
+	digits := v
+	if digits[0] == '-' {
+		digits = digits[1:]
+	}
+	// Fractions, exponents and overflow are errors for encoding/json
+	if len(digits) == 0 || len(digits) > 18 {
+		return errFallback
+	}
+	n := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return errFallback
+		}
+		n = n*10 + int(c-'0')
+	}
+	if v[0] == '-' {
+		n = -n
+	}
+	*dst = n
+	return nil
+}
+
+func (s *lineScanner) float(dst **float64) error {
+	v := s.value()
+	if v[0] == 'n' {
+		// Unlike other fields, a null pointer is reset
+		*dst = nil
+		return nil
+	}
+	// encoding/json parses numbers the same way and rejects out of range ones
+	f, err := strconv.ParseFloat(string(v), 64)
+	if err != nil {
+		return errFallback
+	}
+	*dst = &f
+	return nil
+}
+
+func (s *lineScanner) bool(dst *bool) error {
+	switch v := s.value(); v[0] {
+	case 't':
+		*dst = true
+	case 'f':
+		*dst = false
+	case 'n':
+	default:
+		return errFallback
+	}
+	return nil
+}
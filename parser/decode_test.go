@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sampleLine is a typical assistant line: a tool call with a sizable input
+// that decodeEntry skims past.
+var sampleLine = `{"parentUuid":"p","isSidechain":false,"userType":"external","cwd":"/home/u/proj","sessionId":"s1","version":"2.0.5","gitBranch":"main","type":"assistant","timestamp":"2026-06-01T12:00:00.000Z","requestId":"req_1","uuid":"u",` +
+	`"message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[{"type":"tool_use","id":"toolu_1","name":"Edit","input":{"file_path":"/home/u/proj/main.go","edits":[` +
+	strings.Repeat(`"a\"b\\c\u00e9",`, 200) + `"x"]}}],` +
+	`"stop_reason":null,"usage":{"input_tokens":12,"output_tokens":345,"cache_creation_input_tokens":6789,"cache_read_input_tokens":101112,"cache_creation":{"ephemeral_5m_input_tokens":6789,"ephemeral_1h_input_tokens":0},"service_tier":"standard","server_tool_use":{"web_search_requests":1}}}}`
+
+func FuzzDecodeEntry(f *testing.F) {
+	for _, seed := range []string{
+		sampleLine,
+		// Escaped strings, including in keys
+		`{"timestamp":"2026-06-01T12:00:00Z","requestId":"r\"1","cwd":"C:\\Users\\u","message":{"id":"m\u00e9","model":"\ud83d\ude00"}}`,
+		`{"time\u0073tamp":"t","message":{"usage":{"input_\u0074okens":1}}}`,
+		`{"cwd":"\ud800","version":"a\/b\tc"}`,
+		// Nested content of every JSON type
+		`{"message":{"content":[{"type":"text","text":"hi","n":[1,2.5e3,-0,true,false,null,{"a":{"b":[]}}]}],"usage":{"output_tokens":2}}}`,
+		`{"message":{"content":"plain user text","usage":null}}`,
+		// Duplicate keys: the last one wins
+		`{"requestId":"a","requestId":"b","message":{"id":"x","id":"y","usage":{"input_tokens":1,"input_tokens":2}}}`,
+		`{"message":{"usage":{"cache_creation":{"ephemeral_5m_input_tokens":1},"cache_creation":null}},"message":{"model":"m"}}`,
+		// Keys matching only case-insensitively, and mismatched types
+		`{"RequestID":"r","Message":{"ID":"m","Usage":{"Input_Tokens":3}}}`,
+		`{"isSidechain":"yes","costUSD":"1.5","message":{"usage":{"input_tokens":1.5}}}`,
+		`{"costUSD":1e400}`,
+		`{"costUSD":0.0123,"isSidechain":true}`,
+		`[]`, `null`, `{"message":[]}`, `{`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		var want LogEntry
+		wantErr := json.Unmarshal([]byte(line), &want)
+		var got LogEntry
+		gotErr := decodeEntry([]byte(line), &got, make(map[string]string))
+		if (gotErr == nil) != (wantErr == nil) {
+			t.Fatalf("decodeEntry error %v, json.Unmarshal error %v", gotErr, wantErr)
+		}
+		if wantErr == nil && !reflect.DeepEqual(got, want) {
+			t.Fatalf("decodeEntry = %+v\njson.Unmarshal = %+v", got, want)
+		}
+	})
+}
+
+func BenchmarkDecodeEntry(b *testing.B) {
+	line := []byte(sampleLine)
+	strs := make(map[string]string)
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var e LogEntry
+		if err := decodeEntry(line, &e, strs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	// A session of responses, each logged once per content block, with the
+	// user turns in between
+	var sb strings.Builder
+	for i := range 1000 {
+		line := strings.NewReplacer(`"req_1"`, fmt.Sprintf(`"req_%d"`, i), `"msg_1"`, fmt.Sprintf(`"msg_%d"`, i)).Replace(sampleLine)
+		sb.WriteString(line + "\n" + line + "\n")
+		sb.WriteString(`{"type":"user","timestamp":"2026-06-01T12:00:01.000Z","message":{"role":"user","content":"continue"}}` + "\n")
+	}
+	transcript := sb.String()
+	b.SetBytes(int64(len(transcript)))
+	b.ReportAllocs()
+	for b.Loop() {
+		entries, _ := Parse(strings.NewReader(transcript), "/home/u/.claude/projects/-home-u-proj/s1.jsonl")
+		if len(entries) != 1000 {
+			b.Fatalf("got %d entries, want 1000", len(entries))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// Reasons a transcript line yields no entry.
+const (
+	SkipNoUsage      = "no usage (not an API response)"
+	SkipInvalidJSON  = "invalid JSON"
+	SkipNoTimestamp  = "missing timestamp"
+	SkipBadTimestamp = "unparseable timestamp"
+	SkipZeroTokens   = "zero input and output tokens"
+	SkipNoMessageID  = "missing message.id"
+	SkipNoRequestID  = "missing requestId"
+	SkipTooLong      = "over 10MB even without long strings"
+)
+
+// Benign reports whether lines skipped for reason are expected in any
+// transcript rather than a sign of lost usage.
+func Benign(reason string) bool {
+	return reason == SkipNoUsage
+}
+
+// maxSampleLen bounds the text kept from each sampled line.
+const maxSampleLen = 200
+
+// Sample is a truncated copy of one skipped line.
+type Sample struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+	Text   string `json:"text"`
+}
+
+// Diagnostics explains how one transcript was parsed: why lines were skipped,
+// where reading stopped early and which fields were not understood.
+type Diagnostics struct {
+	Path       string         `json:"path"`
+	Lines      int            `json:"lines"`
+	Requests   int            `json:"requests"`
+	Duplicates int            `json:"duplicate_lines"` // further lines repeating a copy of a request already seen
+	Skipped    map[string]int `json:"skipped"`
+	// Oversized counts lines over MaxLineSize that were read with their long
+	// strings dropped.
+	Oversized int `json:"oversized_lines,omitempty"`
+	// StoppedAt is the line at which reading stopped before the end of the
+	// file, e.g. on a truncated archive; later lines were never read.
+	StoppedAt     int            `json:"stopped_at_line,omitempty"`
+	ReadError     string         `json:"read_error,omitempty"`
+	UnknownFields map[string]int `json:"unknown_fields,omitempty"` // dotted keys of response lines -> lines
+	Samples       []Sample       `json:"samples,omitempty"`
+
+	maxSamples int
+	sampled    map[string]int
+}
+
+// knownFields are the keys of an API response line that Parse reads or
+// knowingly ignores, as dotted paths: top-level keys, message keys and
+// message.usage keys with their nested objects.
+var knownFields = map[string]bool{
+	"parentUuid":                  true,
+	"logicalParentUuid":           true,
+	"isSidechain":                 true,
+	"userType":                    true,
+	"cwd":                         true,
+	"sessionId":                   true,
+	"version":                     true,
+	"gitBranch":                   true,
+	"slug":                        true,
+	"agentId":                     true,
+	"type":                        true,
+	"timestamp":                   true,
+	"requestId":                   true,
+	"uuid":                        true,
+	"message":                     true,
+	"costUSD":                     true,
+	"durationMs":                  true,
+	"isApiErrorMessage":           true,
+	"error":                       true,
+	"isMeta":                      true,
+	"teamName":                    true,
+	"message.id":                  true,
+	"message.type":                true,
+	"message.role":                true,
+	"message.model":               true,
+	"message.content":             true,
+	"message.stop_reason":         true,
+	"message.stop_sequence":       true,
+	"message.usage":               true,
+	"message.container":           true,
+	"message.context_management":  true,
+	"message.usage.input_tokens":  true,
+	"message.usage.output_tokens": true,
+	"message.usage.cache_creation_input_tokens":              true,
+	"message.usage.cache_read_input_tokens":                  true,
+	"message.usage.cache_creation":                           true,
+	"message.usage.cache_creation.ephemeral_5m_input_tokens": true,
+	"message.usage.cache_creation.ephemeral_1h_input_tokens": true,
+	"message.usage.service_tier":                             true,
+	"message.usage.speed":                                    true,
+	"message.usage.server_tool_use":                          true,
+	"message.usage.server_tool_use.web_search_requests":      true,
+}
+
+// Diagnose parses a transcript like Parse, recording up to samples skipped
+// lines per reason.
+func Diagnose(r io.Reader, path string, samples int) *Diagnostics {
+	d := &Diagnostics{
+		Path:       path,
+		Skipped:    make(map[string]int),
+		maxSamples: samples,
+		sampled:    make(map[string]int),
+	}
+	_, stats := parse(r, path, d)
+	d.Lines = stats.LinesRead
+	d.Requests = stats.EntriesNew
+	return d
+}
+
+// skip records a line skipped for reason.
+func (d *Diagnostics) skip(lineNo int, reason string, line []byte) {
+	d.Skipped[reason]++
+	if Benign(reason) || d.sampled[reason] >= d.maxSamples {
+		return
+	}
+	d.sampled[reason]++
+	d.Samples = append(d.Samples, Sample{Line: lineNo, Reason: reason, Text: truncate(line)})
+}
+
+// checkUsage records the fields of a line with a usage object that Parse
+// does not know, so a renamed or new field shows up before its usage is lost,
+// and reports whether the line has a usage object at all.
+func (d *Diagnostics) checkUsage(line []byte) bool {
+	var raw, message, usage map[string]json.RawMessage
+	if json.Unmarshal(line, &raw) != nil || json.Unmarshal(raw["message"], &message) != nil ||
+		json.Unmarshal(message["usage"], &usage) != nil || usage == nil {
+		return false
+	}
+	for key := range raw {
+		d.checkField(key, nil)
+	}
+	for key := range message {
+		d.checkField("message."+key, nil)
+	}
+	for key, value := range usage {
+		d.checkField("message.usage."+key, value)
+	}
+	return true
+}
+
+// checkField counts key if it is unknown, and otherwise the unknown keys of
+// value when it is an object.
+func (d *Diagnostics) checkField(key string, value json.RawMessage) {
+	if !knownFields[key] {
+		if d.UnknownFields == nil {
+			d.UnknownFields = make(map[string]int)
+		}
+		d.UnknownFields[key]++
+		return
+	}
+	var nested map[string]json.RawMessage
+	if len(value) > 0 && value[0] == '{' && json.Unmarshal(value, &nested) == nil {
+		for sub, v := range nested {
+			d.checkField(key+"."+sub, v)
+		}
+	}
+}
+
+// Problems reports whether anything other than benign skips was found.
+func (d *Diagnostics) Problems() bool {
+	if d.StoppedAt > 0 || d.ReadError != "" || len(d.UnknownFields) > 0 {
+		return true
+	}
+	for reason, n := range d.Skipped {
+		if n > 0 && !Benign(reason) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(line []byte) string {
+	if len(line) <= maxSampleLen {
+		return string(line)
+	}
+	cut := maxSampleLen
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return string(line[:cut]) + "…"
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiagnoseUnknownFields(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		line string
+		want map[string]int
+	}{
+		{"known", sampleLine, nil},
+		{"top-level rename", `{"timestamp":"2026-06-01T12:00:00Z","request_id":"r","message":{"id":"m","usage":{"input_tokens":1}}}`,
+			map[string]int{"request_id": 1}},
+		{"message field", `{"timestamp":"2026-06-01T12:00:00Z","requestId":"r","message":{"id":"m","citations":[],"usage":{"input_tokens":1}}}`,
+			map[string]int{"message.citations": 1}},
+		{"nested usage field", `{"timestamp":"2026-06-01T12:00:00Z","requestId":"r","message":{"id":"m","usage":{"input_tokens":1,"server_tool_use":{"web_fetch_requests":2}}}}`,
+			map[string]int{"message.usage.server_tool_use.web_fetch_requests": 1}},
+		{"not a response", `{"type":"user","timestamp":"2026-06-01T12:00:00Z","toolUseResult":{},"message":{"role":"user","content":"hi"}}`, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Diagnose(strings.NewReader(tc.line+"\n"), "/c/projects/p/s.jsonl", 0)
+			if !reflect.DeepEqual(d.UnknownFields, tc.want) {
+				t.Errorf("UnknownFields = %v, want %v", d.UnknownFields, tc.want)
+			}
+			if d.Problems() != (tc.want != nil) {
+				t.Errorf("Problems() = %v", d.Problems())
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// MaxLineSize is the longest line kept whole. Longer lines, usually a large
+// tool result or a pasted image, are read with their long strings dropped.
+const MaxLineSize = 10 * 1024 * 1024
+
+// maxOversizedString is the longest string kept from an oversized line. Usage,
+// IDs, timestamps and model names are far shorter; message content is not.
+const maxOversizedString = 4 * 1024
+
+// LineReader reads JSONL lines of any length. Unlike bufio.Scanner it does not
+// stop at an oversized line: the line is streamed with every string longer
+// than a few KB replaced by "", so its usage can still be decoded without
+// holding the full content in memory, and reading continues with the next line.
+type LineReader struct {
+	r         *bufio.Reader
+	line      []byte
+	oversized bool
+	tooLong   bool
+	err       error
+}
+
+// NewLineReader returns a LineReader reading from r.
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{r: bufio.NewReaderSize(r, 1024*1024)}
+}
+
+// Next advances to the next line, reporting false at the end of the input or
+// on a read error.
+func (lr *LineReader) Next() bool {
+	lr.line = lr.line[:0]
+	lr.oversized, lr.tooLong = false, false
+	var s *shrinker
+	read := 0
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		read += len(chunk)
+		if s != nil {
+			s.write(chunk)
+		} else {
+			lr.line = append(lr.line, chunk...)
+			if len(lr.line) > MaxLineSize {
+				lr.oversized = true
+				s = &shrinker{}
+				s.write(lr.line)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			lr.err = err
+			return false
+		}
+		if err == io.EOF && read == 0 {
+			return false
+		}
+		if s != nil {
+			lr.line = s.out
+			lr.tooLong = s.overflow
+		}
+		lr.line = bytes.TrimSuffix(lr.line, []byte("\n"))
+		lr.line = bytes.TrimSuffix(lr.line, []byte("\r"))
+		return true
+	}
+}
+
+// Bytes returns the current line without its line ending. It is only valid
+// until the next call to Next.
+func (lr *LineReader) Bytes() []byte { return lr.line }
+
+// Oversized reports whether the current line was over MaxLineSize and had its
+// long strings dropped.
+func (lr *LineReader) Oversized() bool { return lr.oversized }
+
+// TooLong reports whether the current line was still over MaxLineSize after
+// dropping long strings, in which case Bytes holds only part of it.
+func (lr *LineReader) TooLong() bool { return lr.tooLong }
+
+// Err returns the read error that stopped Next, if any.
+func (lr *LineReader) Err() error { return lr.err }
+
+// shrinker copies JSON text, emptying strings longer than maxOversizedString.
+type shrinker struct {
+	out      []byte
+	inString bool
+	escaped  bool
+	dropping bool
+	start    int // offset in out of the open string's contents
+	overflow bool
+}
+
+func (s *shrinker) write(p []byte) {
+	for _, c := range p {
+		if s.overflow {
+			return
+		}
+		if !s.inString {
+			if c == '"' {
+				s.inString = true
+				s.start = len(s.out) + 1
+			}
+			s.emit(c)
+			continue
+		}
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case c == '\\':
+			s.escaped = true
+		case c == '"':
+			s.inString, s.dropping = false, false
+			s.emit(c)
+			continue
+		}
+		if s.dropping {
+			continue
+		}
+		s.emit(c)
+		if len(s.out)-s.start > maxOversizedString {
+			s.out = s.out[:s.start]
+			s.dropping = true
+		}
+	}
+}
+
+func (s *shrinker) emit(c byte) {
+	if len(s.out) >= MaxLineSize {
+		s.overflow = true
+		return
+	}
+	s.out = append(s.out, c)
+}
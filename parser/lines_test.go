@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// transcriptLine is an assistant response for request n with the given content
+// blocks.
+func transcriptLine(n int, content string) string {
+	return fmt.Sprintf(`{"type":"assistant","timestamp":"2026-06-01T12:00:0%dZ","requestId":"req_%d","sessionId":"s","message":{"id":"msg_%d","model":"claude-sonnet-4-5","content":%s,"usage":{"input_tokens":%d,"output_tokens":%d}}}`,
+		n, n, n, content, 100*n, 10*n)
+}
+
+// oversizedTranscript returns a transcript with a normal line, a tool result
+// far over MaxLineSize, a line that stays over it after shrinking, and a normal
+// line after them.
+func oversizedTranscript() string {
+	// One string longer than MaxLineSize, as in a pasted image or a large file
+	huge := `[{"type":"tool_result","content":"` + strings.Repeat("x", MaxLineSize+MaxLineSize/5) + `"}]`
+	// Many short strings survive shrinking, so this line is still too long
+	short := `[` + strings.Repeat(`{"type":"text","text":"a"},`, MaxLineSize/25) + `{"type":"text","text":"a"}]`
+	return strings.Join([]string{
+		transcriptLine(1, `[]`),
+		transcriptLine(2, huge),
+		transcriptLine(3, short),
+		transcriptLine(4, `[]`),
+	}, "\n") + "\n"
+}
+
+func TestLineReaderOversized(t *testing.T) {
+	lr := NewLineReader(strings.NewReader(oversizedTranscript()))
+	want := []struct {
+		oversized, tooLong bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+		{false, false},
+	}
+	for i, w := range want {
+		if !lr.Next() {
+			t.Fatalf("line %d: Next() = false, err %v", i+1, lr.Err())
+		}
+		if lr.Oversized() != w.oversized || lr.TooLong() != w.tooLong {
+			t.Errorf("line %d: Oversized() = %v, TooLong() = %v, want %v, %v", i+1, lr.Oversized(), lr.TooLong(), w.oversized, w.tooLong)
+		}
+		if len(lr.Bytes()) > MaxLineSize {
+			t.Errorf("line %d: %d bytes, want at most MaxLineSize", i+1, len(lr.Bytes()))
+		}
+		if i == 1 && !bytes.Contains(lr.Bytes(), []byte(`"content":""`)) {
+			t.Errorf("line 2: long string was not dropped")
+		}
+	}
+	if lr.Next() {
+		t.Errorf("Next() = true after the last line")
+	}
+	if lr.Err() != nil {
+		t.Errorf("Err() = %v", lr.Err())
+	}
+}
+
+func TestParseOversized(t *testing.T) {
+	d := Diagnose(strings.NewReader(oversizedTranscript()), "/c/projects/p/s.jsonl", 1)
+	if d.Lines != 4 || d.Requests != 3 {
+		t.Errorf("Diagnose: %d lines, %d requests, want 4 lines, 3 requests", d.Lines, d.Requests)
+	}
+	if d.Oversized != 1 || d.Skipped[SkipTooLong] != 1 {
+		t.Errorf("Diagnose: %d oversized, %d too long, want 1, 1", d.Oversized, d.Skipped[SkipTooLong])
+	}
+
+	entries, stats := Parse(strings.NewReader(oversizedTranscript()), "/c/projects/p/s.jsonl")
+	if stats.LinesRead != 4 {
+		t.Errorf("LinesRead = %d, want 4", stats.LinesRead)
+	}
+	got := make(map[string][2]int)
+	for _, e := range entries {
+		got[e.Key] = [2]int{e.InputTokens, e.OutputTokens}
+	}
+	want := map[string][2]int{
+		"msg_1:req_1": {100, 10},
+		"msg_2:req_2": {200, 20}, // shrunk, usage intact
+		"msg_4:req_4": {400, 40}, // read after the oversized lines
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d requests, want %d: %v", len(got), len(want), got)
+	}
+	for key, w := range want {
+		if got[key] != w {
+			t.Errorf("%s: tokens %v, want %v", key, got[key], w)
+		}
+	}
+}
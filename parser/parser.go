@@ -0,0 +1,286 @@
+// Package parser reads Claude Code JSONL transcripts into usage entries.
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// LogEntry is the subset of a transcript line needed to account for a request.
+type LogEntry struct {
+	Timestamp   string `json:"timestamp"`
+	RequestID   string `json:"requestId"`
+	GitBranch   string `json:"gitBranch"`
+	Cwd         string `json:"cwd"`
+	SessionID   string `json:"sessionId"`
+	AgentID     string `json:"agentId"`
+	IsSidechain bool   `json:"isSidechain"`
+	Version     string `json:"version"`
+	// CostUSD is the cost older Claude Code versions logged with each response.
+	CostUSD *float64 `json:"costUSD"`
+	Message struct {
+		ID      string          `json:"id"`
+		Model   string          `json:"model"`
+		Content json.RawMessage `json:"content"` // string for user messages, blocks for assistant ones
+		Usage   struct {
+			InputTokens         int `json:"input_tokens"`
+			OutputTokens        int `json:"output_tokens"`
+			CacheCreationTokens int `json:"cache_creation_input_tokens"`
+			CacheReadTokens     int `json:"cache_read_input_tokens"`
+			CacheCreation       struct {
+				Ephemeral5m int `json:"ephemeral_5m_input_tokens"`
+				Ephemeral1h int `json:"ephemeral_1h_input_tokens"`
+			} `json:"cache_creation"`
+			ServiceTier   string `json:"service_tier"`
+			Speed         string `json:"speed"`
+			ServerToolUse struct {
+				WebSearchRequests int `json:"web_search_requests"`
+			} `json:"server_tool_use"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// FileStats counts the lines read from one file and the entries found.
+type FileStats struct {
+	LinesRead   int
+	LinesParsed int
+	EntriesNew  int
+}
+
+// ProjectForFile returns the config root and project directory name for a JSONL
+// file: the parent of the "projects" directory and the component directly below it.
+func ProjectForFile(path string) (root, project string) {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] == "projects" {
+			root = filepath.FromSlash(strings.Join(parts[:i], "/"))
+			if i+1 < len(parts)-1 {
+				project = parts[i+1]
+			}
+			return root, project
+		}
+	}
+	return "", ""
+}
+
+// SessionForFile returns the session a transcript belongs to and, for subagent
+// transcripts stored in a directory named after their session
+// (<session>/subagents/agent-<id>.jsonl), the subagent's name.
+func SessionForFile(path string) (session, agent string) {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	name := strings.TrimSuffix(parts[len(parts)-1], ".jsonl")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] == "projects" {
+			if i+3 < len(parts) {
+				return parts[i+2], name
+			}
+			break
+		}
+	}
+	return name, ""
+}
+
+// ContentBlock is one block of a message's content.
+type ContentBlock struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ToolUseBlocks returns the tool_use blocks of a message's content, which is a
+// plain string rather than a block list for some messages.
+func ToolUseBlocks(content json.RawMessage) []ContentBlock {
+	if len(content) == 0 || content[0] != '[' {
+		return nil
+	}
+	var blocks []ContentBlock
+	if json.Unmarshal(content, &blocks) != nil {
+		return nil
+	}
+	var tools []ContentBlock
+	for _, b := range blocks {
+		if b.Type == "tool_use" && b.Name != "" {
+			tools = append(tools, b)
+		}
+	}
+	return tools
+}
+
+// ParseFile parses a JSONL file and returns its entries in file order. A
+// request logged more than once with different usage, e.g. as a response
+// streamed in, yields one entry per distinct copy, left for a dedup strategy
+// to choose between; lines repeating a copy's usage add only their tools.
+func ParseFile(path string) ([]usage.Entry, FileStats) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, FileStats{}
+	}
+	defer func() { _ = f.Close() }()
+	return Parse(f, path)
+}
+
+// Parse parses a JSONL transcript read from r. The project, session and
+// subagent are derived from path as for ParseFile.
+func Parse(r io.Reader, path string) ([]usage.Entry, FileStats) {
+	return parse(r, path, nil)
+}
+
+// parse implements Parse, explaining skipped lines in d when it is non-nil.
+func parse(r io.Reader, path string, d *Diagnostics) ([]usage.Entry, FileStats) {
+	var entries []usage.Entry
+	copies := make(map[string][]int)      // key -> index in entries of each copy
+	keyTools := make(map[string][]string) // key -> tool calls across every line
+	var stats FileStats
+	root, project := ProjectForFile(path)
+	fileSession, fileAgent := SessionForFile(path)
+	seenTools := make(map[string]bool)
+	strs := make(map[string]string)
+
+	lines := NewLineReader(r)
+	skip := func(reason string) {
+		if d != nil {
+			d.skip(stats.LinesRead, reason, lines.Bytes())
+		}
+	}
+
+	for lines.Next() {
+		stats.LinesRead++
+		if lines.TooLong() {
+			skip(SkipTooLong)
+			continue
+		}
+		if lines.Oversized() && d != nil {
+			d.Oversized++
+		}
+		var entry LogEntry
+		if decodeEntry(lines.Bytes(), &entry, strs) != nil {
+			skip(SkipInvalidJSON)
+			continue
+		}
+		stats.LinesParsed++
+		hasUsage := d == nil || d.checkUsage(lines.Bytes())
+		if entry.Timestamp == "" || (entry.Message.Usage.InputTokens == 0 && entry.Message.Usage.OutputTokens == 0) {
+			switch {
+			case !hasUsage:
+				skip(SkipNoUsage)
+			case entry.Timestamp == "":
+				skip(SkipNoTimestamp)
+			default:
+				skip(SkipZeroTokens)
+			}
+			continue
+		}
+		if entry.Message.ID == "" || entry.RequestID == "" {
+			if entry.Message.ID == "" {
+				skip(SkipNoMessageID)
+			} else {
+				skip(SkipNoRequestID)
+			}
+			continue
+		}
+
+		key := entry.Message.ID + ":" + entry.RequestID
+		t, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil {
+			skip(SkipBadTimestamp)
+			continue
+		}
+		date := t.UTC().Format("2006-01-02")
+		model := entry.Message.Model
+		if model == "" {
+			model = "unknown"
+		}
+
+		// Fast mode detection: append :fast suffix for separate pricing
+		if entry.Message.Usage.Speed == "fast" {
+			model = model + ":fast"
+		}
+
+		// Cache write breakdown: split 5m vs 1h when breakdown is available
+		cacheWrite5m := entry.Message.Usage.CacheCreationTokens
+		cacheWrite1h := 0
+		if cc := entry.Message.Usage.CacheCreation; cc.Ephemeral5m+cc.Ephemeral1h > 0 {
+			cacheWrite5m = cc.Ephemeral5m
+			cacheWrite1h = cc.Ephemeral1h
+		}
+
+		session, agent := entry.SessionID, entry.AgentID
+		if session == "" {
+			session = fileSession
+		}
+		sidechain := entry.IsSidechain || fileAgent != ""
+		if agent == "" && sidechain {
+			agent = fileAgent
+		}
+		if !sidechain {
+			agent = ""
+		}
+
+		// Each content block of a response is logged on its own line with the
+		// same usage, so collect tool calls from every line of the request
+		for _, block := range ToolUseBlocks(entry.Message.Content) {
+			if block.ID != "" {
+				if seenTools[key+"\x00"+block.ID] {
+					continue
+				}
+				seenTools[key+"\x00"+block.ID] = true
+			}
+			keyTools[key] = append(keyTools[key], block.Name)
+		}
+
+		e := usage.Entry{
+			Key:                 key,
+			Date:                date,
+			Timestamp:           t.Unix(),
+			Model:               model,
+			Project:             project,
+			Root:                root,
+			Branch:              entry.GitBranch,
+			Cwd:                 entry.Cwd,
+			Session:             session,
+			Agent:               agent,
+			Sidechain:           sidechain,
+			Version:             entry.Version,
+			InputTokens:         entry.Message.Usage.InputTokens,
+			OutputTokens:        entry.Message.Usage.OutputTokens,
+			CacheCreationTokens: cacheWrite5m,
+			CacheWrite1hTokens:  cacheWrite1h,
+			CacheReadTokens:     entry.Message.Usage.CacheReadTokens,
+			WebSearchRequests:   entry.Message.Usage.ServerToolUse.WebSearchRequests,
+		}
+		if entry.CostUSD != nil {
+			e.LoggedCostUSD, e.HasLoggedCost = *entry.CostUSD, true
+		}
+		repeated := false
+		for _, i := range copies[key] {
+			repeated = repeated || entries[i].SameUsage(&e)
+		}
+		if repeated {
+			if d != nil {
+				d.Duplicates++
+			}
+			continue
+		}
+		if len(copies[key]) == 0 {
+			stats.EntriesNew++
+		}
+		copies[key] = append(copies[key], len(entries))
+		entries = append(entries, e)
+	}
+	// Every copy carries all of the request's tool calls, whichever is counted
+	for i := range entries {
+		entries[i].Tools = slices.Clip(keyTools[entries[i].Key])
+	}
+	if err := lines.Err(); err != nil && d != nil {
+		d.StoppedAt = stats.LinesRead + 1
+		d.ReadError = err.Error()
+	}
+	return entries, stats
+}
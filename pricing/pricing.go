@@ -1,9 +1,52 @@
-package main
+// Package pricing holds per-model token prices.
+package pricing
 
-// Model pricing (per million tokens, USD)
-// See: https://platform.claude.com/docs/en/about-claude/pricing
+import "strings"
+
+// Rates are the prices for one model, in USD per million tokens.
+type Rates struct {
+	Input        float64
+	Output       float64
+	CacheWrite   float64 // 5-minute ephemeral (1.25x base input)
+	CacheWrite1h float64 // 1-hour ephemeral (2x base input)
+	CacheRead    float64
+}
 
-var modelPricing = map[string]ModelPricing{
+// Table maps model names to their rates. Fast mode models carry a ":fast"
+// suffix, and the "default" and "default:openai" entries price Anthropic and
+// OpenAI models missing from the table.
+type Table map[string]Rates
+
+// WebSearchCost is the price of one server-side web search: $10 per 1,000 requests.
+const WebSearchCost = 0.01
+
+// Lookup returns the rates for model, falling back to the default rates of its
+// provider for unknown models, and to "default" when the table has none.
+func (t Table) Lookup(model string) Rates {
+	p := t[model]
+	if p.Input == 0 && p.Output == 0 {
+		p = t[DefaultFor(model)]
+	}
+	if p.Input == 0 && p.Output == 0 {
+		p = t["default"]
+	}
+	return p
+}
+
+// DefaultFor names the entry pricing model when it is missing from the table:
+// "default:openai" for OpenAI models (gpt-*, codex-*, o1, o3, ...) and
+// "default" for everything else.
+func DefaultFor(model string) string {
+	if strings.HasPrefix(model, "gpt-") || strings.HasPrefix(model, "codex-") ||
+		len(model) >= 2 && model[0] == 'o' && model[1] >= '0' && model[1] <= '9' {
+		return "default:openai"
+	}
+	return "default"
+}
+
+// Default is the built-in price table (per million tokens, USD).
+// See: https://platform.claude.com/docs/en/about-claude/pricing
+var Default = Table{
 	// Default pricing (used for unknown models)
 	"default": {Input: 3.0, Output: 15.0, CacheWrite: 3.75, CacheWrite1h: 6.0, CacheRead: 0.30},
 
@@ -46,4 +89,20 @@ var modelPricing = map[string]ModelPricing{
 	"sonnet:fast": {Input: 18.0, Output: 90.0, CacheWrite: 22.50, CacheWrite1h: 36.0, CacheRead: 1.80},
 	"opus":      {Input: 5.0, Output: 25.0, CacheWrite: 6.25, CacheWrite1h: 10.0, CacheRead: 0.50},
 	"opus:fast": {Input: 30.0, Output: 150.0, CacheWrite: 37.50, CacheWrite1h: 60.0, CacheRead: 3.0},
+
+	// OpenAI models used by the Codex CLI, which has no cache write charge.
+	// Unknown OpenAI models are priced like gpt-5
+	// See: https://platform.openai.com/docs/pricing
+	"default:openai":     {Input: 1.25, Output: 10.0, CacheRead: 0.125},
+	"gpt-5":              {Input: 1.25, Output: 10.0, CacheRead: 0.125},
+	"gpt-5-codex":        {Input: 1.25, Output: 10.0, CacheRead: 0.125},
+	"gpt-5.1":            {Input: 1.25, Output: 10.0, CacheRead: 0.125},
+	"gpt-5.1-codex":      {Input: 1.25, Output: 10.0, CacheRead: 0.125},
+	"gpt-5.1-codex-mini": {Input: 0.25, Output: 2.0, CacheRead: 0.025},
+	"gpt-5-mini":         {Input: 0.25, Output: 2.0, CacheRead: 0.025},
+	"gpt-5-nano":         {Input: 0.05, Output: 0.40, CacheRead: 0.005},
+	"codex-mini-latest":  {Input: 1.50, Output: 6.0, CacheRead: 0.375},
+	"o3":                 {Input: 2.0, Output: 8.0, CacheRead: 0.50},
+	"o4-mini":            {Input: 1.10, Output: 4.40, CacheRead: 0.275},
+	"gpt-4.1":            {Input: 2.0, Output: 8.0, CacheRead: 0.50},
 }
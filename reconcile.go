@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// exportKey is a date and model of a usage export. The model is empty for
+// exports that are not broken down by model.
+type exportKey struct{ date, model string }
+
+// exportUsage is what an export reports for one date and model.
+type exportUsage struct {
+	input, output, cacheWrite, cacheRead int
+	costUSD                              float64
+	hasTokens, hasCost                   bool
+}
+
+func (u *exportUsage) add(src *exportUsage) {
+	u.input += src.input
+	u.output += src.output
+	u.cacheWrite += src.cacheWrite
+	u.cacheRead += src.cacheRead
+	u.costUSD += src.costUSD
+	u.hasTokens = u.hasTokens || src.hasTokens
+	u.hasCost = u.hasCost || src.hasCost
+}
+
+// usageExport is a Console export or Admin API report, summed by date and model.
+type usageExport map[exportKey]*exportUsage
+
+func (x usageExport) add(k exportKey, u *exportUsage) {
+	k.model = strings.ToLower(strings.TrimSpace(k.model))
+	if x[k] == nil {
+		x[k] = &exportUsage{}
+	}
+	x[k].add(u)
+}
+
+// loadExport reads a saved export: JSON from the Admin API usage or cost
+// report endpoints, or a CSV downloaded from the Console.
+func loadExport(path string, x usageExport) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		err = parseAdminReport(trimmed, x)
+	} else {
+		err = parseConsoleCSV(bytes.NewReader(data), x)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// adminReport is a page of /v1/organizations/usage_report/messages or
+// /v1/organizations/cost_report.
+type adminReport struct {
+	Data []struct {
+		StartingAt string `json:"starting_at"`
+		EndingAt   string `json:"ending_at"`
+		Results    []struct {
+			Model               *string `json:"model"`
+			UncachedInputTokens *int    `json:"uncached_input_tokens"`
+			OutputTokens        int     `json:"output_tokens"`
+			CacheReadTokens     int     `json:"cache_read_input_tokens"`
+			CacheCreation       struct {
+				Ephemeral5m int `json:"ephemeral_5m_input_tokens"`
+				Ephemeral1h int `json:"ephemeral_1h_input_tokens"`
+			} `json:"cache_creation"`
+			// Amount is the cost in cents as a decimal string
+			Amount   *string `json:"amount"`
+			Currency string  `json:"currency"`
+		} `json:"results"`
+	} `json:"data"`
+}
+
+// parseAdminReport reads a saved API response, or a JSON array of the pages
+// of one.
+func parseAdminReport(data []byte, x usageExport) error {
+	var pages []adminReport
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &pages); err != nil {
+			return err
+		}
+	} else {
+		var page adminReport
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+	rows := 0
+	for _, page := range pages {
+		for _, bucket := range page.Data {
+			start, err := time.Parse(time.RFC3339, bucket.StartingAt)
+			if err != nil {
+				return fmt.Errorf("bucket starting_at: %w", err)
+			}
+			if end, err := time.Parse(time.RFC3339, bucket.EndingAt); err == nil && end.Sub(start) > 24*time.Hour {
+				return fmt.Errorf("bucket from %s to %s spans more than a day; request bucket_width=1d or finer", bucket.StartingAt, bucket.EndingAt)
+			}
+			date := start.UTC().Format("2006-01-02")
+			for _, r := range bucket.Results {
+				var u exportUsage
+				if r.UncachedInputTokens != nil {
+					u.hasTokens = true
+					u.input = *r.UncachedInputTokens
+					u.output = r.OutputTokens
+					u.cacheWrite = r.CacheCreation.Ephemeral5m + r.CacheCreation.Ephemeral1h
+					u.cacheRead = r.CacheReadTokens
+				}
+				if r.Amount != nil {
+					if r.Currency != "" && r.Currency != "USD" {
+						return fmt.Errorf("cost in %s, only USD is supported", r.Currency)
+					}
+					cents, err := strconv.ParseFloat(*r.Amount, 64)
+					if err != nil {
+						return fmt.Errorf("amount %q: %w", *r.Amount, err)
+					}
+					u.hasCost = true
+					u.costUSD = cents / 100
+				}
+				if !u.hasTokens && !u.hasCost {
+					continue
+				}
+				model := ""
+				if r.Model != nil {
+					model = *r.Model
+				}
+				x.add(exportKey{date, model}, &u)
+				rows++
+			}
+		}
+	}
+	if rows == 0 {
+		return errors.New("no usage or cost results in the report")
+	}
+	return nil
+}
+
+// Console CSV columns by normalized header. Each value is read from the first
+// group of columns present, so an export carrying both a total and its parts
+// is not counted twice. Cache writes of both durations are summed, as in the
+// local tables.
+var (
+	csvDateColumns   = [][]string{{"usage_date_utc"}, {"date"}, {"usage_date"}, {"day"}, {"starting_at"}}
+	csvModelColumns  = [][]string{{"model"}, {"model_version"}, {"model_name"}}
+	csvInputColumns  = [][]string{{"usage_input_tokens_no_cache"}, {"uncached_input_tokens"}, {"input_tokens_no_cache"}, {"input_tokens"}}
+	csvOutputColumns = [][]string{{"usage_output_tokens"}, {"output_tokens"}}
+	csvWriteColumns  = [][]string{
+		{"usage_input_tokens_cache_write_5m", "usage_input_tokens_cache_write_1h"},
+		{"ephemeral_5m_input_tokens", "ephemeral_1h_input_tokens"},
+		{"cache_creation_input_tokens"},
+		{"cache_write_tokens"},
+	}
+	csvReadColumns = [][]string{{"usage_input_tokens_cache_read"}, {"cache_read_input_tokens"}, {"cache_read_tokens"}}
+	csvCostColumns = [][]string{{"cost_usd"}, {"total_cost_usd"}, {"amount_usd"}, {"cost"}}
+)
+
+// normalizeHeader lowercases a CSV header and turns punctuation and spaces
+// into underscores, so "Usage Date (UTC)" matches usage_date_utc.
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	underscore := false
+	for _, c := range strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+			underscore = false
+		} else {
+			underscore = true
+		}
+	}
+	return b.String()
+}
+
+// parseConsoleCSV reads a usage or cost CSV exported from the Console. Columns
+// are found by header; unknown columns such as workspace or API key are
+// ignored and their rows summed.
+func parseConsoleCSV(r io.Reader, x usageExport) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[normalizeHeader(h)] = i
+	}
+	find := func(groups [][]string) []int {
+		for _, names := range groups {
+			var found []int
+			for _, name := range names {
+				if i, ok := columns[name]; ok {
+					found = append(found, i)
+				}
+			}
+			if len(found) > 0 {
+				return found
+			}
+		}
+		return nil
+	}
+	dateCols, modelCols := find(csvDateColumns), find(csvModelColumns)
+	inputCols, outputCols := find(csvInputColumns), find(csvOutputColumns)
+	writeCols, readCols := find(csvWriteColumns), find(csvReadColumns)
+	costCols := find(csvCostColumns)
+	if len(dateCols) == 0 {
+		return errors.New("no date column, want usage_date_utc or date")
+	}
+	hasTokens := len(inputCols)+len(outputCols)+len(writeCols)+len(readCols) > 0
+	hasCost := len(costCols) > 0
+	if !hasTokens && !hasCost {
+		return errors.New("no token or cost columns")
+	}
+
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return err
+		}
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		sum := func(cols []int) (float64, error) {
+			var total float64
+			for _, i := range cols {
+				v := strings.NewReplacer("$", "", ",", "").Replace(field(i))
+				if v == "" {
+					continue
+				}
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return 0, fmt.Errorf("line %d: %s %q: %w", line, header[i], field(i), err)
+				}
+				total += f
+			}
+			return total, nil
+		}
+
+		rawDate := field(dateCols[0])
+		if rawDate == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", rawDate[:min(len(rawDate), 10)])
+		if err != nil {
+			return fmt.Errorf("line %d: date %q: %w", line, rawDate, err)
+		}
+		model := ""
+		if len(modelCols) > 0 {
+			model = field(modelCols[0])
+		}
+		u := exportUsage{hasTokens: hasTokens, hasCost: hasCost}
+		for _, c := range []struct {
+			dst  *int
+			cols []int
+		}{{&u.input, inputCols}, {&u.output, outputCols}, {&u.cacheWrite, writeCols}, {&u.cacheRead, readCols}} {
+			n, err := sum(c.cols)
+			if err != nil {
+				return err
+			}
+			*c.dst = int(math.Round(n))
+		}
+		if u.costUSD, err = sum(costCols); err != nil {
+			return err
+		}
+		x.add(exportKey{date.Format("2006-01-02"), model}, &u)
+	}
+	if line == 1 {
+		return errors.New("no rows")
+	}
+	return nil
+}
+
+// reconcileUsage is one side of a reconciled row.
+type reconcileUsage struct {
+	InputTokens      int     `json:"input_tokens"`
+	OutputTokens     int     `json:"output_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	Tokens           int     `json:"tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+func (u *reconcileUsage) add(src reconcileUsage) {
+	u.InputTokens += src.InputTokens
+	u.OutputTokens += src.OutputTokens
+	u.CacheWriteTokens += src.CacheWriteTokens
+	u.CacheReadTokens += src.CacheReadTokens
+	u.Tokens += src.Tokens
+	u.CostUSD += src.CostUSD
+}
+
+// reconcileRow compares local usage with the export for a date and model.
+// Diffs are local minus export, and zero for figures the export lacks.
+type reconcileRow struct {
+	Date      string         `json:"date"`
+	Model     string         `json:"model,omitempty"`
+	Local     reconcileUsage `json:"local"`
+	Export    reconcileUsage `json:"export"`
+	TokenDiff int            `json:"token_diff"`
+	CostDiff  float64        `json:"cost_diff_usd"`
+}
+
+// setDiffs fills in the diffs for the figures the export has.
+func (r *reconcileRow) setDiffs(hasTokens, hasCost bool) {
+	if hasTokens {
+		r.TokenDiff = r.Local.Tokens - r.Export.Tokens
+	}
+	if hasCost {
+		r.CostDiff = r.Local.CostUSD - r.Export.CostUSD
+	}
+}
+
+func (r *reconcileRow) differs() bool {
+	return r.TokenDiff != 0 || math.Round(r.CostDiff*100) != 0
+}
+
+type reconcileReport struct {
+	Exports   []string       `json:"exports"`
+	Since     string         `json:"since"`
+	Until     string         `json:"until"`
+	ByModel   bool           `json:"by_model"`
+	HasTokens bool           `json:"export_has_tokens"`
+	HasCost   bool           `json:"export_has_cost"`
+	Compared  int            `json:"compared_rows"`
+	Differing int            `json:"differing_rows"`
+	Total     reconcileRow   `json:"total"`
+	Rows      []reconcileRow `json:"rows"`
+}
+
+// buildReconcileReport lines entries up with the export over the export's
+// dates, leaving out export rows before cutoff unless it is empty. Rows are
+// per date and model when every export row names a model and per date
+// otherwise; a side with no usage for a row counts as zero.
+func buildReconcileReport(entries map[string]*usage.Entry, x usageExport, mode usage.CostMode, cutoff string) *reconcileReport {
+	r := &reconcileReport{Exports: []string{}, Rows: []reconcileRow{}, ByModel: true}
+	for k, u := range x {
+		if k.date < cutoff {
+			continue
+		}
+		if r.Since == "" || k.date < r.Since {
+			r.Since = k.date
+		}
+		r.Until = max(r.Until, k.date)
+		r.ByModel = r.ByModel && k.model != ""
+		r.HasTokens = r.HasTokens || u.hasTokens
+		r.HasCost = r.HasCost || u.hasCost
+	}
+	if r.Since < cutoff {
+		r.Since = cutoff
+	}
+
+	rows := make(map[exportKey]*reconcileRow)
+	row := func(k exportKey) *reconcileRow {
+		if !r.ByModel {
+			k.model = ""
+		}
+		if rows[k] == nil {
+			rows[k] = &reconcileRow{Date: k.date, Model: k.model}
+		}
+		return rows[k]
+	}
+	for k, u := range x {
+		if k.date < cutoff {
+			continue
+		}
+		row(k).Export.add(reconcileUsage{
+			InputTokens:      u.input,
+			OutputTokens:     u.output,
+			CacheWriteTokens: u.cacheWrite,
+			CacheReadTokens:  u.cacheRead,
+			Tokens:           u.input + u.output + u.cacheWrite + u.cacheRead,
+			CostUSD:          u.costUSD,
+		})
+	}
+	for date, day := range usage.AggregateBy(entries, func(e *usage.Entry) string { return e.Date }) {
+		if date < r.Since || date > r.Until {
+			continue
+		}
+		for model, u := range day.Models {
+			// Exports name the model without the fast mode suffix
+			row(exportKey{date, strings.ToLower(strings.TrimSuffix(model, ":fast"))}).Local.add(reconcileUsage{
+				InputTokens:      u.Input,
+				OutputTokens:     u.Output,
+				CacheWriteTokens: u.CacheWrite + u.CacheWrite1h,
+				CacheReadTokens:  u.CacheRead,
+				Tokens:           u.Input + u.Output + u.CacheWrite + u.CacheWrite1h + u.CacheRead,
+				CostUSD:          mode.Cost(&usage.DayUsage{Models: map[string]*usage.Usage{model: u}}, pricing.Default),
+			})
+		}
+	}
+
+	r.Total.Date = "Total"
+	for _, row := range rows {
+		row.setDiffs(r.HasTokens, r.HasCost)
+		if row.differs() {
+			r.Differing++
+		}
+		r.Total.Local.add(row.Local)
+		r.Total.Export.add(row.Export)
+		r.Rows = append(r.Rows, *row)
+	}
+	r.Compared = len(r.Rows)
+	r.Total.setDiffs(r.HasTokens, r.HasCost)
+	sort.Slice(r.Rows, func(i, j int) bool {
+		if r.Rows[i].Date != r.Rows[j].Date {
+			return r.Rows[i].Date < r.Rows[j].Date
+		}
+		return r.Rows[i].Model < r.Rows[j].Model
+	})
+	return r
+}
+
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	var exports stringList
+	fs.Var(&exports, "export", "Console CSV export or saved Admin API usage/cost report JSON (repeatable)")
+	common := addCommonFlags(fs)
+	diffOnly := fs.Bool("diff-only", false, "only list rows that differ")
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	opts := common.options(fs)
+	if len(exports) == 0 {
+		fmt.Fprintf(os.Stderr, "error: reconcile requires --export\n")
+		os.Exit(1)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+	// The export decides the range unless --days narrows it
+	opts.showAll = opts.showAll || !opts.daysExplicit
+
+	x := make(usageExport)
+	for _, path := range exports {
+		if err := loadExport(path, x); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	entries := common.loadEntries(opts)
+	for key, e := range entries {
+		// Codex requests are billed by OpenAI, not Anthropic
+		if e.Source == source.Codex {
+			delete(entries, key)
+		}
+	}
+	cutoff := ""
+	if opts.daysExplicit {
+		cutoff = opts.cutoff()
+	}
+	r := buildReconcileReport(entries, x, opts.costMode, cutoff)
+	if r.Until == "" {
+		fmt.Fprintf(os.Stderr, "error: the export has no rows on or after %s\n", cutoff)
+		os.Exit(1)
+	}
+	r.Exports = exports
+	if *diffOnly {
+		kept := r.Rows[:0]
+		for _, row := range r.Rows {
+			if row.differs() {
+				kept = append(kept, row)
+			}
+		}
+		r.Rows = kept
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return
+	}
+	printReconcileReport(r)
+}
+
+// formatTokenDiff formats a signed token difference.
+func formatTokenDiff(n int) string {
+	if n < 0 {
+		return "-" + render.FormatNumber(-n)
+	}
+	return "+" + render.FormatNumber(n)
+}
+
+func printReconcileReport(r *reconcileReport) {
+	fmt.Printf("Local usage vs %s, %s to %s\n\n", strings.Join(r.Exports, ", "), r.Since, r.Until)
+	fmt.Printf("%s of %s rows differ (local minus export)\n\n", render.FormatNumber(r.Differing), render.FormatNumber(r.Compared))
+
+	modelWidth := 0
+	if r.ByModel {
+		modelWidth = 5
+		for _, row := range r.Rows {
+			modelWidth = max(modelWidth, len(row.Model))
+		}
+	}
+	rowFormat := "%-10s"
+	header := []any{"Date"}
+	width := 10
+	if r.ByModel {
+		rowFormat += fmt.Sprintf(" %%-%ds", modelWidth)
+		header = append(header, "Model")
+		width += modelWidth + 1
+	}
+	if r.HasTokens {
+		rowFormat += " %17s %17s %14s"
+		header = append(header, "LocalTokens", "ExportTokens", "TokenDiff")
+		width += 51
+	}
+	if r.HasCost {
+		rowFormat += " %12s %12s %12s"
+		header = append(header, "LocalCost", "ExportCost", "CostDiff")
+		width += 39
+	}
+	rowFormat += "\n"
+	values := func(row reconcileRow) []any {
+		v := []any{row.Date}
+		if r.ByModel {
+			v = append(v, row.Model)
+		}
+		if r.HasTokens {
+			v = append(v, render.FormatNumber(row.Local.Tokens), render.FormatNumber(row.Export.Tokens), formatTokenDiff(row.TokenDiff))
+		}
+		if r.HasCost {
+			v = append(v, render.FormatDollars(row.Local.CostUSD), render.FormatDollars(row.Export.CostUSD), render.FormatDiff(row.CostDiff))
+		}
+		return v
+	}
+
+	fmt.Printf(rowFormat, header...)
+	fmt.Println(strings.Repeat("-", width))
+	for _, row := range r.Rows {
+		fmt.Printf(rowFormat, values(row)...)
+	}
+	fmt.Println(strings.Repeat("-", width))
+	fmt.Printf(rowFormat, values(r.Total)...)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+func TestParseConsoleCSV(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		csv  string
+		want usageExport
+	}{
+		{
+			name: "console usage export",
+			csv: "\ufeffusage_date_utc,model_version,api_key,usage_input_tokens_no_cache,usage_input_tokens_cache_write_5m,usage_input_tokens_cache_write_1h,usage_input_tokens_cache_read,usage_output_tokens\n" +
+				"2026-09-08,claude-haiku-4-5,key-a,10,20,5,30,40\n" +
+				"2026-09-08,claude-haiku-4-5,key-b,1,0,0,0,2\n",
+			want: usageExport{{"2026-09-08", "claude-haiku-4-5"}: {input: 11, output: 42, cacheWrite: 25, cacheRead: 30, hasTokens: true}},
+		},
+		{
+			name: "spaced headers and timestamps",
+			csv: "Usage Date (UTC),Model,Input Tokens,Output Tokens,Cache Creation Input Tokens,Cache Read Input Tokens\n" +
+				"2026-09-08T00:00:00Z,Claude-Sonnet-4-5,100,200,300,400\n",
+			want: usageExport{{"2026-09-08", "claude-sonnet-4-5"}: {input: 100, output: 200, cacheWrite: 300, cacheRead: 400, hasTokens: true}},
+		},
+		{
+			name: "cost export without models",
+			csv:  "date,workspace,cost_usd\n2026-09-08,a,\"$1,234.50\"\n2026-09-08,b,0.50\n2026-09-09,a,\n",
+			want: usageExport{
+				{"2026-09-08", ""}: {costUSD: 1235, hasCost: true},
+				{"2026-09-09", ""}: {hasCost: true},
+			},
+		},
+		{
+			name: "total input is not added to its parts",
+			csv:  "date,model,usage_input_tokens_no_cache,input_tokens,usage_output_tokens\n2026-09-08,m,7,99,1\n",
+			want: usageExport{{"2026-09-08", "m"}: {input: 7, output: 1, hasTokens: true}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x := make(usageExport)
+			if err := parseConsoleCSV(strings.NewReader(tc.csv), x); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(x, tc.want) {
+				t.Errorf("got %v, want %v", dumpExport(x), dumpExport(tc.want))
+			}
+		})
+	}
+
+	for _, bad := range []string{
+		"model,input_tokens\nm,1\n",
+		"date,model,workspace\n2026-09-08,m,a\n",
+		"date,input_tokens\n",
+		"date,input_tokens\n09/08/2026,1\n",
+	} {
+		if err := parseConsoleCSV(strings.NewReader(bad), make(usageExport)); err == nil {
+			t.Errorf("parseConsoleCSV(%q) = nil, want an error", bad)
+		}
+	}
+}
+
+func TestParseAdminReport(t *testing.T) {
+	usagePage := func(date, model string, input int) string {
+		return `{"data":[{"starting_at":"` + date + `T00:00:00Z","ending_at":"` + date + `T23:59:59Z","results":[` +
+			`{"model":"` + model + `","uncached_input_tokens":` + strings.Repeat("1", input) + `,"output_tokens":2,"cache_read_input_tokens":3,"cache_creation":{"ephemeral_5m_input_tokens":4,"ephemeral_1h_input_tokens":5}}]}],"has_more":true}`
+	}
+	for _, tc := range []struct {
+		name string
+		json string
+		want usageExport
+	}{
+		{
+			name: "one usage page",
+			json: usagePage("2026-09-08", "claude-opus-4-1", 1),
+			want: usageExport{{"2026-09-08", "claude-opus-4-1"}: {input: 1, output: 2, cacheWrite: 9, cacheRead: 3, hasTokens: true}},
+		},
+		{
+			name: "array of pages",
+			json: "[" + usagePage("2026-09-08", "m", 1) + "," + usagePage("2026-09-08", "m", 2) + "," + usagePage("2026-09-09", "m", 1) + "]",
+			want: usageExport{
+				{"2026-09-08", "m"}: {input: 12, output: 4, cacheWrite: 18, cacheRead: 6, hasTokens: true},
+				{"2026-09-09", "m"}: {input: 1, output: 2, cacheWrite: 9, cacheRead: 3, hasTokens: true},
+			},
+		},
+		{
+			name: "cost in cents",
+			json: `{"data":[{"starting_at":"2026-09-08T00:00:00Z","ending_at":"2026-09-09T00:00:00Z","results":[` +
+				`{"amount":"1234.5","currency":"USD","model":"m"},{"amount":"50","currency":"USD","model":"m"},{"amount":"7","model":null}]}]}`,
+			want: usageExport{
+				{"2026-09-08", "m"}: {costUSD: 12.845, hasCost: true},
+				{"2026-09-08", ""}:  {costUSD: 0.07, hasCost: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x := make(usageExport)
+			if err := parseAdminReport([]byte(tc.json), x); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(x, tc.want) {
+				t.Errorf("got %v, want %v", dumpExport(x), dumpExport(tc.want))
+			}
+		})
+	}
+
+	for _, bad := range []string{
+		`{"data":[]}`,
+		`{"data":[{"starting_at":"2026-09-01T00:00:00Z","ending_at":"2026-10-01T00:00:00Z","results":[{"amount":"1"}]}]}`,
+		`{"data":[{"starting_at":"2026-09-08T00:00:00Z","results":[{"amount":"1","currency":"EUR"}]}]}`,
+	} {
+		if err := parseAdminReport([]byte(bad), make(usageExport)); err == nil {
+			t.Errorf("parseAdminReport(%s) = nil, want an error", bad)
+		}
+	}
+}
+
+func TestBuildReconcileReport(t *testing.T) {
+	entries := map[string]*usage.Entry{
+		"a": {Date: "2026-09-07", Model: "claude-sonnet-4-5", InputTokens: 5},
+		"b": {Date: "2026-09-08", Model: "claude-sonnet-4-5", InputTokens: 10},
+		"c": {Date: "2026-09-08", Model: "claude-sonnet-4-5:fast", InputTokens: 20},
+	}
+	x := usageExport{
+		{"2026-09-07", "claude-sonnet-4-5"}: {input: 5, hasTokens: true},
+		{"2026-09-08", "claude-sonnet-4-5"}: {input: 30, hasTokens: true},
+	}
+
+	r := buildReconcileReport(entries, x, usage.CostCalculate, "")
+	if r.Since != "2026-09-07" || r.Compared != 2 || r.Differing != 0 {
+		t.Errorf("no cutoff: since %s, %d rows, %d differ, want since 2026-09-07, 2 rows, 0 differ", r.Since, r.Compared, r.Differing)
+	}
+
+	r = buildReconcileReport(entries, x, usage.CostCalculate, "2026-09-08")
+	if r.Since != "2026-09-08" || r.Compared != 1 || r.Total.Export.Tokens != 30 || r.Total.Local.Tokens != 30 {
+		t.Errorf("cutoff: since %s, %d rows, %d local and %d export tokens, want since 2026-09-08, 1 row, 30 and 30",
+			r.Since, r.Compared, r.Total.Local.Tokens, r.Total.Export.Tokens)
+	}
+}
+
+func dumpExport(x usageExport) map[exportKey]exportUsage {
+	out := make(map[exportKey]exportUsage, len(x))
+	for k, u := range x {
+		out[k] = *u
+	}
+	return out
+}
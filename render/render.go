@@ -0,0 +1,209 @@
+// Package render formats usage as the CLI's text tables.
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// FormatNumber formats n with thousands separators.
+func FormatNumber(n int) string {
+	s := fmt.Sprintf("%d", n)
+	if len(s) <= 3 {
+		return s
+	}
+	var result []byte
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			result = append(result, ',')
+		}
+		result = append(result, byte(c))
+	}
+	return string(result)
+}
+
+// FormatDollars formats v as dollars and cents with thousands separators.
+func FormatDollars(v float64) string {
+	cents := int(math.Round(v * 100))
+	whole := cents / 100
+	frac := cents % 100
+	if frac < 0 {
+		frac = -frac
+	}
+	return fmt.Sprintf("$%s.%02d", FormatNumber(whole), frac)
+}
+
+// Table writes one row per key of dayUsage, sorted by key, under the given
+// column label (e.g. "Date" or "Root"). It returns the cost of each row in order.
+// In usage.CostDisplay mode two more columns show the cost logged by the client
+// and how far it is from the calculated cost of the same requests.
+func Table(w io.Writer, label string, dayUsage map[string]*usage.DayUsage, prices pricing.Table, mode usage.CostMode) []float64 {
+	var dates []string
+	labelWidth := 15
+	for d := range dayUsage {
+		dates = append(dates, d)
+		labelWidth = max(labelWidth, len(d))
+	}
+	sort.Strings(dates)
+
+	display := mode == usage.CostDisplay
+	width := labelWidth + 111
+	rowFormat := fmt.Sprintf("%%-%ds %%17s %%17s %%17s %%17s %%12s %%12s %%12s", labelWidth)
+	header := []any{label, "Input", "Output", "CacheWrite", "CacheRead", "Cost", "AllRegular", "AllFast"}
+	if display {
+		width += 26
+		rowFormat += " %12s %12s"
+		header = append(header, "Logged", "Diff")
+	}
+	rowFormat += "\n"
+	fmt.Fprintf(w, rowFormat, header...)
+	fmt.Fprintln(w, strings.Repeat("-", width))
+
+	var totalInput, totalOutput, totalCacheWrite, totalCacheRead int
+	var totalCost, totalCostRegular, totalCostFast, totalLogged, totalDiff float64
+	var dailyCosts []float64
+	for _, date := range dates {
+		day := dayUsage[date]
+		input, output, cacheWrite, cacheRead := usage.SumTokens(day)
+		cost := mode.Cost(day, prices)
+		costRegular := usage.CostAllRegular(day, prices)
+		costFast := usage.CostAllFast(day, prices)
+		totalInput += input
+		totalOutput += output
+		totalCacheWrite += cacheWrite
+		totalCacheRead += cacheRead
+		totalCost += cost
+		totalCostRegular += costRegular
+		totalCostFast += costFast
+		dailyCosts = append(dailyCosts, cost)
+		row := []any{
+			date,
+			FormatNumber(input),
+			FormatNumber(output),
+			FormatNumber(cacheWrite),
+			FormatNumber(cacheRead),
+			FormatDollars(cost),
+			FormatDollars(costRegular),
+			FormatDollars(costFast),
+		}
+		if display {
+			logged, calculated := usage.LoggedCost(day, prices)
+			totalLogged += logged
+			totalDiff += logged - calculated
+			row = append(row, FormatDollars(logged), FormatDiff(logged-calculated))
+		}
+		fmt.Fprintf(w, rowFormat, row...)
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", width))
+	total := []any{
+		"Total",
+		FormatNumber(totalInput),
+		FormatNumber(totalOutput),
+		FormatNumber(totalCacheWrite),
+		FormatNumber(totalCacheRead),
+		FormatDollars(totalCost),
+		FormatDollars(totalCostRegular),
+		FormatDollars(totalCostFast),
+	}
+	if display {
+		total = append(total, FormatDollars(totalLogged), FormatDiff(totalDiff))
+	}
+	fmt.Fprintf(w, rowFormat, total...)
+	return dailyCosts
+}
+
+// FormatDiff formats a signed dollar difference, e.g. "+$1.20" or "-$0.35".
+func FormatDiff(v float64) string {
+	if math.Round(v*100) < 0 {
+		return "-" + FormatDollars(-v)
+	}
+	return "+" + FormatDollars(v)
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	n := len(sorted)
+	idx := int(math.Ceil(p/100.0*float64(n))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= n {
+		idx = n - 1
+	}
+	return sorted[idx]
+}
+
+// Projection is one row of the month-to-date projections.
+type Projection struct {
+	Name    string  `json:"name"`
+	Daily   float64 `json:"daily"`
+	Monthly float64 `json:"monthly"`
+	Yearly  float64 `json:"yearly"`
+}
+
+// Projections extrapolates daily cost statistics to the current month and
+// a year. It returns nil when fewer than two days were sampled.
+func Projections(dailyCosts []float64) (stats []Projection, daysInMonth int) {
+	if len(dailyCosts) < 2 {
+		return nil, 0
+	}
+
+	sorted := make([]float64, len(dailyCosts))
+	copy(sorted, dailyCosts)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, c := range dailyCosts {
+		sum += c
+	}
+	mean := sum / float64(len(dailyCosts))
+	p50 := percentile(sorted, 50)
+	p75 := percentile(sorted, 75)
+	p99 := percentile(sorted, 99)
+
+	now := time.Now().UTC()
+	daysInMonth = time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	for _, stat := range []struct {
+		name string
+		val  float64
+	}{
+		{"Mean", mean},
+		{"p50", p50},
+		{"p75", p75},
+		{"p99", p99},
+	} {
+		stats = append(stats, Projection{
+			Name:    stat.name,
+			Daily:   stat.val,
+			Monthly: stat.val * float64(daysInMonth),
+			Yearly:  stat.val * 365,
+		})
+	}
+	return stats, daysInMonth
+}
+
+// WriteProjections writes the projections of dailyCosts, if there are enough days.
+func WriteProjections(w io.Writer, dailyCosts []float64) {
+	stats, _ := Projections(dailyCosts)
+	if stats == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "\nProjections (MTD, %d days sampled)\n", len(dailyCosts))
+	fmt.Fprintf(w, "%-10s %12s %12s %12s\n", "", "Daily", "Monthly", "Yearly")
+	for _, stat := range stats {
+		fmt.Fprintf(w, "  %-8s %12s %12s %12s\n",
+			stat.Name,
+			FormatDollars(stat.Daily),
+			FormatDollars(stat.Monthly),
+			FormatDollars(stat.Yearly))
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// Chart geometry for the HTML report, in SVG user units.
+const (
+	chartWidth  = 960
+	chartHeight = 260
+	chartLeft   = 70
+	chartBottom = 40
+	chartTop    = 16
+)
+
+var chartColors = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+type htmlBar struct {
+	X, Y, W, H float64
+	Color      string
+	Title      string
+}
+
+type htmlAxisLabel struct {
+	X, Y float64
+	Text string
+}
+
+type htmlChart struct {
+	Bars    []htmlBar
+	XLabels []htmlAxisLabel
+	YLabels []htmlAxisLabel
+	Legend  []htmlLegendItem
+}
+
+type htmlLegendItem struct {
+	Color string
+	Label string
+}
+
+type htmlSlice struct {
+	Path  string
+	Color string
+	Label string
+	Cost  string
+	Share string
+}
+
+type htmlRow struct {
+	Label      string
+	Input      string
+	Output     string
+	CacheWrite string
+	CacheRead  string
+	Cost       string
+	Share      string
+	Logged     string // logged cost, with --cost-mode display
+	Diff       string // logged minus calculated cost
+}
+
+type htmlReport struct {
+	Title       string
+	Generated   string
+	Range       string
+	Total       string
+	CostChart   htmlChart
+	TokenChart  htmlChart
+	Pie         []htmlSlice
+	Days        []htmlRow
+	TotalRow    htmlRow
+	Projects    []htmlRow
+	Projections []render.Projection
+	DaysSampled int
+	Display     bool // show the logged cost beside the calculated one
+}
+
+// writeHTMLReport writes a single self-contained HTML page for the report range.
+// All charts are inline SVG and all styles are embedded, so the file works offline.
+func writeHTMLReport(w io.Writer, cache *store.Cache, opts reportOptions) error {
+	dayUsage := usage.RollupDays(cache.Rollups, opts.cutoff())
+	var dates []string
+	for d := range dayUsage {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	report := htmlReport{
+		Title:     "Claude Code usage",
+		Generated: time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+		Range:     opts.describe(),
+		Display:   opts.costMode == usage.CostDisplay,
+	}
+
+	// Daily rows and totals
+	dailyCosts := make([]float64, len(dates))
+	var total usage.Usage
+	var totalCost, totalLogged, totalDiff float64
+	for i, date := range dates {
+		day := dayUsage[date]
+		dailyCosts[i] = opts.costMode.Cost(day, pricing.Default)
+		totalCost += dailyCosts[i]
+		for _, u := range day.Models {
+			total.Merge(u)
+		}
+		row := usageRow(date, day, dailyCosts[i], 0)
+		if report.Display {
+			logged, calculated := usage.LoggedCost(day, pricing.Default)
+			totalLogged += logged
+			totalDiff += logged - calculated
+			row.Logged, row.Diff = render.FormatDollars(logged), render.FormatDiff(logged-calculated)
+		}
+		report.Days = append(report.Days, row)
+	}
+	report.Total = render.FormatDollars(totalCost)
+	report.TotalRow = usageRow("Total", &usage.DayUsage{Models: map[string]*usage.Usage{"": &total}}, totalCost, 0)
+	report.TotalRow.Logged, report.TotalRow.Diff = render.FormatDollars(totalLogged), render.FormatDiff(totalDiff)
+
+	// Daily cost bars
+	maxCost := 0.0
+	for _, c := range dailyCosts {
+		maxCost = max(maxCost, c)
+	}
+	report.CostChart = barChart(dates, func(i int) []float64 { return []float64{dailyCosts[i]} },
+		maxCost, []string{"Cost"}, func(v float64) string { return render.FormatDollars(v) })
+
+	// Stacked token categories
+	categories := []string{"Input", "Output", "Cache write", "Cache read"}
+	tokenSeries := make([][]float64, len(dates))
+	maxTokens := 0.0
+	for i, date := range dates {
+		input, output, cacheWrite, cacheRead := usage.SumTokens(dayUsage[date])
+		tokenSeries[i] = []float64{float64(input), float64(output), float64(cacheWrite), float64(cacheRead)}
+		maxTokens = max(maxTokens, float64(input+output+cacheWrite+cacheRead))
+	}
+	report.TokenChart = barChart(dates, func(i int) []float64 { return tokenSeries[i] },
+		maxTokens, categories, func(v float64) string { return render.FormatNumber(int(v)) })
+
+	// Per-model pie
+	models, projects := usage.SumRollups(cache.Rollups, opts.cutoff(), "")
+	report.Pie = pieChart(models, totalCost, opts.costMode)
+
+	// Per-project table, most expensive first
+	type projectCost struct {
+		name string
+		day  *usage.DayUsage
+		cost float64
+	}
+	var projectCosts []projectCost
+	for name, projectModels := range projects {
+		day := &usage.DayUsage{Models: projectModels}
+		projectCosts = append(projectCosts, projectCost{name, day, opts.costMode.Cost(day, pricing.Default)})
+	}
+	sort.Slice(projectCosts, func(i, j int) bool {
+		if projectCosts[i].cost != projectCosts[j].cost {
+			return projectCosts[i].cost > projectCosts[j].cost
+		}
+		return projectCosts[i].name < projectCosts[j].name
+	})
+	for _, p := range projectCosts {
+		report.Projects = append(report.Projects, usageRow(p.name, p.day, p.cost, totalCost))
+	}
+
+	if !opts.showAll {
+		report.Projections, _ = render.Projections(dailyCosts)
+		report.DaysSampled = len(dailyCosts)
+	}
+
+	return htmlReportTemplate.Execute(w, report)
+}
+
+// usageRow formats a table row; share is omitted when total is zero.
+func usageRow(label string, day *usage.DayUsage, cost, total float64) htmlRow {
+	input, output, cacheWrite, cacheRead := usage.SumTokens(day)
+	row := htmlRow{
+		Label:      label,
+		Input:      render.FormatNumber(input),
+		Output:     render.FormatNumber(output),
+		CacheWrite: render.FormatNumber(cacheWrite),
+		CacheRead:  render.FormatNumber(cacheRead),
+		Cost:       render.FormatDollars(cost),
+	}
+	if total > 0 {
+		row.Share = fmt.Sprintf("%.1f%%", cost/total*100)
+	}
+	return row
+}
+
+// barChart lays out one (optionally stacked) bar per date scaled to maxValue.
+func barChart(dates []string, values func(i int) []float64, maxValue float64, series []string, format func(float64) string) htmlChart {
+	var chart htmlChart
+	if len(series) > 1 {
+		for i, name := range series {
+			chart.Legend = append(chart.Legend, htmlLegendItem{chartColors[i%len(chartColors)], name})
+		}
+	}
+	if len(dates) == 0 || maxValue <= 0 {
+		return chart
+	}
+	plotWidth := float64(chartWidth - chartLeft - 10)
+	plotHeight := float64(chartHeight - chartBottom - chartTop)
+	slot := plotWidth / float64(len(dates))
+	barWidth := math.Max(slot*0.8, 1)
+	labelEvery := int(math.Ceil(float64(len(dates)) / 12))
+
+	for i, date := range dates {
+		x := chartLeft + float64(i)*slot + (slot-barWidth)/2
+		y := float64(chartHeight - chartBottom)
+		for s, v := range values(i) {
+			h := v / maxValue * plotHeight
+			y -= h
+			chart.Bars = append(chart.Bars, htmlBar{
+				X: x, Y: y, W: barWidth, H: h,
+				Color: chartColors[s%len(chartColors)],
+				Title: fmt.Sprintf("%s %s: %s", date, series[s], format(v)),
+			})
+		}
+		if i%labelEvery == 0 {
+			chart.XLabels = append(chart.XLabels, htmlAxisLabel{X: x + barWidth/2, Y: chartHeight - chartBottom + 16, Text: date[5:]})
+		}
+	}
+	for i := 0; i <= 4; i++ {
+		v := maxValue * float64(i) / 4
+		chart.YLabels = append(chart.YLabels, htmlAxisLabel{
+			X:    chartLeft - 6,
+			Y:    float64(chartHeight-chartBottom) - plotHeight*float64(i)/4 + 4,
+			Text: format(v),
+		})
+	}
+	return chart
+}
+
+// pieChart lays out one SVG arc per model, largest share first.
+func pieChart(models map[string]*usage.Usage, totalCost float64, mode usage.CostMode) []htmlSlice {
+	type modelCost struct {
+		name string
+		cost float64
+	}
+	var costs []modelCost
+	for model, u := range models {
+		costs = append(costs, modelCost{model, mode.Cost(&usage.DayUsage{Models: map[string]*usage.Usage{model: u}}, pricing.Default)})
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].cost != costs[j].cost {
+			return costs[i].cost > costs[j].cost
+		}
+		return costs[i].name < costs[j].name
+	})
+
+	const cx, cy, r = 110.0, 110.0, 100.0
+	var slices []htmlSlice
+	angle := -math.Pi / 2
+	for i, c := range costs {
+		if totalCost <= 0 {
+			break
+		}
+		share := c.cost / totalCost
+		sweep := share * 2 * math.Pi
+		var path string
+		if share >= 0.9999 {
+			path = fmt.Sprintf("M %.2f %.2f m -%.2f 0 a %.2f %.2f 0 1 0 %.2f 0 a %.2f %.2f 0 1 0 -%.2f 0 Z", cx, cy, r, r, r, 2*r, r, r, 2*r)
+		} else {
+			x1, y1 := cx+r*math.Cos(angle), cy+r*math.Sin(angle)
+			x2, y2 := cx+r*math.Cos(angle+sweep), cy+r*math.Sin(angle+sweep)
+			largeArc := 0
+			if sweep > math.Pi {
+				largeArc = 1
+			}
+			path = fmt.Sprintf("M %.2f %.2f L %.2f %.2f A %.2f %.2f 0 %d 1 %.2f %.2f Z", cx, cy, x1, y1, r, r, largeArc, x2, y2)
+		}
+		angle += sweep
+		slices = append(slices, htmlSlice{
+			Path:  path,
+			Color: chartColors[i%len(chartColors)],
+			Label: c.name,
+			Cost:  render.FormatDollars(c.cost),
+			Share: fmt.Sprintf("%.1f%%", share*100),
+		})
+	}
+	return slices
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"dollars": render.FormatDollars}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} — {{.Range}}</title>
+<style>
+body { font: 14px/1.4 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; margin: 2em auto; max-width: 1000px; padding: 0 1em; }
+h1 { margin-bottom: 0; }
+h2 { margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: .2em; }
+.meta { color: #666; margin-top: .2em; }
+.total { font-size: 2em; font-weight: 600; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: .3em .6em; border-bottom: 1px solid #eee; white-space: nowrap; }
+th { text-align: left; background: #f6f6f6; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr.total td { font-weight: 600; border-top: 2px solid #ccc; }
+svg text { font-size: 11px; fill: #555; }
+.legend span { display: inline-block; margin-right: 1.2em; }
+.swatch { display: inline-block; width: .9em; height: .9em; margin-right: .3em; vertical-align: -1px; }
+.pie { display: flex; align-items: center; gap: 2em; }
+rect:hover, path:hover { opacity: .75; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Range}} · generated {{.Generated}}</p>
+<p class="total">{{.Total}}</p>
+
+<h2>Daily cost</h2>
+{{template "chart" .CostChart}}
+
+<h2>Daily tokens by category</h2>
+{{template "chart" .TokenChart}}
+
+<h2>Cost by model</h2>
+<div class="pie">
+<svg width="220" height="220" viewBox="0 0 220 220">
+{{range .Pie}}<path d="{{.Path}}" fill="{{.Color}}"><title>{{.Label}}: {{.Cost}} ({{.Share}})</title></path>
+{{end}}</svg>
+<table>
+<tr><th>Model</th><th class="num">Cost</th><th class="num">Share</th></tr>
+{{range .Pie}}<tr><td><span class="swatch" style="background: {{.Color}}"></span>{{.Label}}</td><td class="num">{{.Cost}}</td><td class="num">{{.Share}}</td></tr>
+{{end}}</table>
+</div>
+
+<h2>Projects</h2>
+<table>
+<tr><th>Project</th><th class="num">Input</th><th class="num">Output</th><th class="num">Cache write</th><th class="num">Cache read</th><th class="num">Cost</th><th class="num">Share</th></tr>
+{{range .Projects}}<tr><td>{{.Label}}</td><td class="num">{{.Input}}</td><td class="num">{{.Output}}</td><td class="num">{{.CacheWrite}}</td><td class="num">{{.CacheRead}}</td><td class="num">{{.Cost}}</td><td class="num">{{.Share}}</td></tr>
+{{end}}</table>
+
+<h2>Daily breakdown</h2>
+<table>
+<tr><th>Date</th><th class="num">Input</th><th class="num">Output</th><th class="num">Cache write</th><th class="num">Cache read</th><th class="num">Cost</th>{{if .Display}}<th class="num">Logged</th><th class="num">Diff</th>{{end}}</tr>
+{{range .Days}}<tr><td>{{.Label}}</td><td class="num">{{.Input}}</td><td class="num">{{.Output}}</td><td class="num">{{.CacheWrite}}</td><td class="num">{{.CacheRead}}</td><td class="num">{{.Cost}}</td>{{if $.Display}}<td class="num">{{.Logged}}</td><td class="num">{{.Diff}}</td>{{end}}</tr>
+{{end}}{{with .TotalRow}}<tr class="total"><td>{{.Label}}</td><td class="num">{{.Input}}</td><td class="num">{{.Output}}</td><td class="num">{{.CacheWrite}}</td><td class="num">{{.CacheRead}}</td><td class="num">{{.Cost}}</td>{{if $.Display}}<td class="num">{{.Logged}}</td><td class="num">{{.Diff}}</td>{{end}}</tr>{{end}}
+</table>
+{{if .Projections}}
+<h2>Projections</h2>
+<p class="meta">Month to date, {{.DaysSampled}} days sampled</p>
+<table>
+<tr><th></th><th class="num">Daily</th><th class="num">Monthly</th><th class="num">Yearly</th></tr>
+{{range .Projections}}<tr><td>{{.Name}}</td><td class="num">{{dollars .Daily}}</td><td class="num">{{dollars .Monthly}}</td><td class="num">{{dollars .Yearly}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+{{define "chart"}}{{if .Legend}}<p class="legend">{{range .Legend}}<span><span class="swatch" style="background: {{.Color}}"></span>{{.Label}}</span>{{end}}</p>{{end}}
+<svg width="100%" viewBox="0 0 960 260" preserveAspectRatio="xMidYMid meet">
+<line x1="70" y1="220" x2="950" y2="220" stroke="#ccc"/>
+{{range .YLabels}}<text x="{{.X}}" y="{{.Y}}" text-anchor="end">{{.Text}}</text>
+{{end}}{{range .Bars}}<rect x="{{printf "%.2f" .X}}" y="{{printf "%.2f" .Y}}" width="{{printf "%.2f" .W}}" height="{{printf "%.2f" .H}}" fill="{{.Color}}"><title>{{.Title}}</title></rect>
+{{end}}{{range .XLabels}}<text x="{{printf "%.2f" .X}}" y="{{.Y}}" text-anchor="middle">{{.Text}}</text>
+{{end}}</svg>
+{{end}}`))
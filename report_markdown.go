@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// writeMarkdownReport writes the daily table, totals, per-model breakdown and
+// projections as GitHub-flavored Markdown tables with right-aligned numbers.
+func writeMarkdownReport(w io.Writer, cache *store.Cache, opts reportOptions) error {
+	cutoff := opts.cutoff()
+	dayUsage := usage.RollupDays(cache.Rollups, cutoff)
+	var dates []string
+	for d := range dayUsage {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Claude Code usage: %s\n\n", opts.describe())
+
+	// --cost-mode display adds the logged cost and its difference from the
+	// calculated one
+	display := opts.costMode == usage.CostDisplay
+	header, align := "| Date | Input | Output | CacheWrite | CacheRead | Cost | AllRegular | AllFast |", "|:-----|------:|-------:|-----------:|----------:|-----:|-----------:|--------:|"
+	if display {
+		header, align = header+" Logged | Diff |", align+"-------:|-----:|"
+	}
+	b.WriteString(header + "\n" + align + "\n")
+	var totalInput, totalOutput, totalCacheWrite, totalCacheRead int
+	var totalCost, totalCostRegular, totalCostFast, totalLogged, totalDiff float64
+	var dailyCosts []float64
+	for _, date := range dates {
+		day := dayUsage[date]
+		input, output, cacheWrite, cacheRead := usage.SumTokens(day)
+		cost := opts.costMode.Cost(day, pricing.Default)
+		costRegular := usage.CostAllRegular(day, pricing.Default)
+		costFast := usage.CostAllFast(day, pricing.Default)
+		totalInput += input
+		totalOutput += output
+		totalCacheWrite += cacheWrite
+		totalCacheRead += cacheRead
+		totalCost += cost
+		totalCostRegular += costRegular
+		totalCostFast += costFast
+		dailyCosts = append(dailyCosts, cost)
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s |", date,
+			render.FormatNumber(input), render.FormatNumber(output), render.FormatNumber(cacheWrite), render.FormatNumber(cacheRead),
+			render.FormatDollars(cost), render.FormatDollars(costRegular), render.FormatDollars(costFast))
+		if display {
+			logged, calculated := usage.LoggedCost(day, pricing.Default)
+			totalLogged += logged
+			totalDiff += logged - calculated
+			fmt.Fprintf(&b, " %s | %s |", render.FormatDollars(logged), render.FormatDiff(logged-calculated))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "| **Total** | **%s** | **%s** | **%s** | **%s** | **%s** | **%s** | **%s** |",
+		render.FormatNumber(totalInput), render.FormatNumber(totalOutput), render.FormatNumber(totalCacheWrite), render.FormatNumber(totalCacheRead),
+		render.FormatDollars(totalCost), render.FormatDollars(totalCostRegular), render.FormatDollars(totalCostFast))
+	if display {
+		fmt.Fprintf(&b, " **%s** | **%s** |", render.FormatDollars(totalLogged), render.FormatDiff(totalDiff))
+	}
+	b.WriteString("\n")
+
+	models, _ := usage.SumRollups(cache.Rollups, cutoff, "")
+	type modelCost struct {
+		name  string
+		usage *usage.Usage
+		cost  float64
+	}
+	var modelCosts []modelCost
+	for model, u := range models {
+		modelCosts = append(modelCosts, modelCost{model, u, opts.costMode.Cost(&usage.DayUsage{Models: map[string]*usage.Usage{model: u}}, pricing.Default)})
+	}
+	sort.Slice(modelCosts, func(i, j int) bool {
+		if modelCosts[i].cost != modelCosts[j].cost {
+			return modelCosts[i].cost > modelCosts[j].cost
+		}
+		return modelCosts[i].name < modelCosts[j].name
+	})
+	b.WriteString("\n### By model\n\n")
+	b.WriteString("| Model | Input | Output | CacheWrite | CacheRead | Cost | Share |\n")
+	b.WriteString("|:------|------:|-------:|-----------:|----------:|-----:|------:|\n")
+	for _, m := range modelCosts {
+		share := 0.0
+		if totalCost > 0 {
+			share = m.cost / totalCost * 100
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s | %.1f%% |\n", m.name,
+			render.FormatNumber(m.usage.Input), render.FormatNumber(m.usage.Output),
+			render.FormatNumber(m.usage.CacheWrite+m.usage.CacheWrite1h), render.FormatNumber(m.usage.CacheRead),
+			render.FormatDollars(m.cost), share)
+	}
+
+	if !opts.showAll {
+		if stats, _ := render.Projections(dailyCosts); stats != nil {
+			fmt.Fprintf(&b, "\n### Projections (MTD, %d days sampled)\n\n", len(dailyCosts))
+			b.WriteString("| | Daily | Monthly | Yearly |\n")
+			b.WriteString("|:--|------:|--------:|-------:|\n")
+			for _, stat := range stats {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", stat.Name,
+					render.FormatDollars(stat.Daily), render.FormatDollars(stat.Monthly), render.FormatDollars(stat.Yearly))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// serveRefreshInterval bounds how stale served data can get when no filesystem
+// notification arrives (e.g. on platforms without inotify).
+const serveRefreshInterval = time.Minute
+
+// usageServer keeps the cache warm in the background and serves snapshots of it.
+type usageServer struct {
+	mu          sync.RWMutex
+	cache       *store.Cache
+	sources     source.Set
+	costMode    usage.CostMode
+	dirty       bool
+	updated     time.Time
+	metrics     []byte
+	fingerprint string
+}
+
+// refresh brings the cache up to date and rebuilds the precomputed metrics and
+// the fingerprint used for API ETags.
+func (s *usageServer) refresh(cacheValid bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if refreshCache(s.cache, s.sources, cacheValid) || s.metrics == nil {
+		s.dirty = true
+		s.metrics = renderMetrics(s.cache.Rollups, pricing.Default, s.costMode)
+		s.fingerprint = cacheFingerprint(s.cache)
+	}
+	s.updated = time.Now()
+}
+
+func (s *usageServer) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirty {
+		_ = s.cache.Save()
+		s.dirty = false
+	}
+}
+
+func (s *usageServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	body, updated := s.metrics, s.updated
+	s.mu.RUnlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(body)
+	fmt.Fprintf(w, "# HELP ccusage_last_refresh_timestamp_seconds Time the usage cache was last refreshed.\n")
+	fmt.Fprintf(w, "# TYPE ccusage_last_refresh_timestamp_seconds gauge\n")
+	fmt.Fprintf(w, "ccusage_last_refresh_timestamp_seconds %d\n", updated.Unix())
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	metricsAddr := fs.String("metrics", "", "address to serve Prometheus /metrics on (e.g. :9464); totals are gauges, not counters, as they drop when logs are deleted")
+	httpAddr := fs.String("http", "", "address to serve the JSON API on (e.g. :8080)")
+	dedup := addDedupFlag(fs)
+	costModeName := addCostModeFlag(fs)
+	srcFlags := addSourceFlags(fs)
+	_ = fs.Parse(args)
+	strategy := dedupStrategy(*dedup)
+	mode := costMode(*costModeName)
+
+	if *metricsAddr == "" && *httpAddr == "" {
+		fmt.Fprintf(os.Stderr, "error: serve requires --metrics and/or --http\n")
+		os.Exit(1)
+	}
+
+	cache, cacheValid := store.Open(false, false)
+	cache.SetDedup(strategy)
+	s := &usageServer{cache: cache, sources: srcFlags.sources(), costMode: mode}
+	s.refresh(cacheValid)
+	s.save()
+
+	// Both endpoints share one listener when given the same address
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if *metricsAddr != "" {
+		muxFor(*metricsAddr).HandleFunc("GET /metrics", s.handleMetrics)
+	}
+	if *httpAddr != "" {
+		mux := muxFor(*httpAddr)
+		mux.HandleFunc("GET /api/daily", s.handleDaily)
+		mux.HandleFunc("GET /api/models", s.handleModels)
+		mux.HandleFunc("GET /api/projects", s.handleProjects)
+		mux.HandleFunc("GET /api/sessions/{id}", s.handleSession)
+		mux.HandleFunc("GET /api/projections", s.handleProjections)
+	}
+	for addr, mux := range muxes {
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				fmt.Fprintf(os.Stderr, "error: serve %s: %v\n", addr, err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	var events <-chan struct{}
+	w, err := newDirWatcher()
+	if err == nil {
+		w.sync(cache.Dirs)
+		events = w.events
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	refreshTicker := time.NewTicker(serveRefreshInterval)
+	defer refreshTicker.Stop()
+	saveTicker := time.NewTicker(watchSaveInterval)
+	defer saveTicker.Stop()
+
+	for {
+		select {
+		case <-events:
+			drainEvents(events)
+			s.refresh(true)
+		case <-refreshTicker.C:
+			s.refresh(true)
+		case <-saveTicker.C:
+			s.save()
+		case <-sigs:
+			s.save()
+			return
+		}
+		if w != nil {
+			s.mu.RLock()
+			w.sync(cache.Dirs)
+			s.mu.RUnlock()
+		}
+	}
+}
+
+// renderMetrics renders lifetime usage totals from the daily rollups in the
+// Prometheus text exposition format, labelled by model, project and speed.
+// They are gauges, not counters: the totals drop when logs are deleted or the
+// dedup strategy changes, which would read as counter resets.
+func renderMetrics(rollups map[string]*usage.DayRollup, prices pricing.Table, mode usage.CostMode) []byte {
+	type series struct{ model, project string }
+	totals := make(map[series]*usage.Usage)
+	for _, r := range rollups {
+		for project, models := range r.Projects {
+			for model, u := range models {
+				k := series{model, project}
+				if totals[k] == nil {
+					totals[k] = &usage.Usage{}
+				}
+				totals[k].Merge(u)
+			}
+		}
+	}
+	keys := make([]series, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].model != keys[j].model {
+			return keys[i].model < keys[j].model
+		}
+		return keys[i].project < keys[j].project
+	})
+	labels := func(k series) string {
+		speed := "regular"
+		if strings.HasSuffix(k.model, ":fast") {
+			speed = "fast"
+		}
+		return fmt.Sprintf(`model="%s",project="%s",speed="%s"`,
+			escapeLabel(strings.TrimSuffix(k.model, ":fast")), escapeLabel(k.project), speed)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# HELP ccusage_tokens Tokens used in the logs on disk, by token type. A gauge: it drops when logs are deleted or --dedup changes.\n")
+	buf.WriteString("# TYPE ccusage_tokens gauge\n")
+	for _, k := range keys {
+		u := totals[k]
+		for _, t := range []struct {
+			name  string
+			value int
+		}{
+			{"input", u.Input},
+			{"output", u.Output},
+			{"cache_write_5m", u.CacheWrite},
+			{"cache_write_1h", u.CacheWrite1h},
+			{"cache_read", u.CacheRead},
+		} {
+			fmt.Fprintf(&buf, "ccusage_tokens{%s,type=\"%s\"} %d\n", labels(k), t.name, t.value)
+		}
+	}
+	buf.WriteString("# HELP ccusage_web_search_requests Server-side web search requests in the logs on disk. A gauge: it drops when logs are deleted or --dedup changes.\n")
+	buf.WriteString("# TYPE ccusage_web_search_requests gauge\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "ccusage_web_search_requests{%s} %d\n", labels(k), totals[k].WebSearchRequests)
+	}
+	buf.WriteString("# HELP ccusage_cost_usd Estimated cost in US dollars of the logs on disk. A gauge: it drops when logs are deleted or --dedup changes.\n")
+	buf.WriteString("# TYPE ccusage_cost_usd gauge\n")
+	for _, k := range keys {
+		cost := mode.Cost(&usage.DayUsage{Models: map[string]*usage.Usage{k.model: totals[k]}}, prices)
+		fmt.Fprintf(&buf, "ccusage_cost_usd{%s} %g\n", labels(k), cost)
+	}
+	return buf.Bytes()
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
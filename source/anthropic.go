@@ -0,0 +1,138 @@
+package source
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/parser"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// anthropicAPI reads JSONL dumps of raw Anthropic Messages API responses, one
+// per line, either bare or wrapped as {"timestamp", "response"}.
+type anthropicAPI struct {
+	dirs []string
+}
+
+// NewAnthropicAPI returns the source for Messages API response dumps under dirs.
+func NewAnthropicAPI(dirs []string) Source {
+	return &anthropicAPI{dirs: absDirs(dirs)}
+}
+
+func (a *anthropicAPI) Name() string   { return AnthropicAPI }
+func (a *anthropicAPI) Dirs() []string { return a.dirs }
+
+// apiMessage is the subset of a Messages API response needed to account for it.
+type apiMessage struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Model   string          `json:"model"`
+	Content json.RawMessage `json:"content"`
+	Usage   struct {
+		InputTokens         int `json:"input_tokens"`
+		OutputTokens        int `json:"output_tokens"`
+		CacheCreationTokens int `json:"cache_creation_input_tokens"`
+		CacheReadTokens     int `json:"cache_read_input_tokens"`
+		CacheCreation       struct {
+			Ephemeral5m int `json:"ephemeral_5m_input_tokens"`
+			Ephemeral1h int `json:"ephemeral_1h_input_tokens"`
+		} `json:"cache_creation"`
+		ServerToolUse struct {
+			WebSearchRequests int `json:"web_search_requests"`
+		} `json:"server_tool_use"`
+	} `json:"usage"`
+}
+
+// apiLine is one dump line: a bare response, or a response wrapped with the
+// time it was received.
+type apiLine struct {
+	apiMessage
+	Timestamp string      `json:"timestamp"`
+	Response  *apiMessage `json:"response"`
+}
+
+// Parse keys entries by message ID. Responses without a timestamp are dated by
+// the file's modification time. The project is the first directory below the
+// dump directory, and the session is the file name.
+func (a *anthropicAPI) Parse(log Log) ([]usage.Entry, parser.FileStats) {
+	var entries []usage.Entry
+	seen := make(map[string]bool)
+	var stats parser.FileStats
+
+	var root, project string
+	for _, dir := range a.dirs {
+		if rel, ok := strings.CutPrefix(log.Name, dir+string(filepath.Separator)); ok {
+			root = dir
+			if first, _, nested := strings.Cut(filepath.ToSlash(rel), "/"); nested {
+				project = first
+			}
+			break
+		}
+	}
+	session := strings.TrimSuffix(filepath.Base(log.Name), ".jsonl")
+
+	lines := parser.NewLineReader(log)
+	for lines.Next() {
+		stats.LinesRead++
+		var line apiLine
+		if lines.TooLong() || json.Unmarshal(lines.Bytes(), &line) != nil {
+			continue
+		}
+		stats.LinesParsed++
+		msg := &line.apiMessage
+		if line.Response != nil {
+			msg = line.Response
+		}
+		if msg.Type != "message" || msg.ID == "" || (msg.Usage.InputTokens == 0 && msg.Usage.OutputTokens == 0) {
+			continue
+		}
+		t := log.ModTime
+		if line.Timestamp != "" {
+			parsed, err := time.Parse(time.RFC3339, line.Timestamp)
+			if err != nil {
+				continue
+			}
+			t = parsed
+		}
+		key := AnthropicAPI + ":" + msg.ID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		cacheWrite5m := msg.Usage.CacheCreationTokens
+		cacheWrite1h := 0
+		if cc := msg.Usage.CacheCreation; cc.Ephemeral5m+cc.Ephemeral1h > 0 {
+			cacheWrite5m = cc.Ephemeral5m
+			cacheWrite1h = cc.Ephemeral1h
+		}
+		var tools []string
+		for _, block := range parser.ToolUseBlocks(msg.Content) {
+			tools = append(tools, block.Name)
+		}
+		model := msg.Model
+		if model == "" {
+			model = "unknown"
+		}
+		entries = append(entries, usage.Entry{
+			Key:                 key,
+			Date:                t.UTC().Format("2006-01-02"),
+			Timestamp:           t.Unix(),
+			Model:               model,
+			Project:             project,
+			Root:                root,
+			Session:             session,
+			Tools:               tools,
+			InputTokens:         msg.Usage.InputTokens,
+			OutputTokens:        msg.Usage.OutputTokens,
+			CacheCreationTokens: cacheWrite5m,
+			CacheWrite1hTokens:  cacheWrite1h,
+			CacheReadTokens:     msg.Usage.CacheReadTokens,
+			WebSearchRequests:   msg.Usage.ServerToolUse.WebSearchRequests,
+		})
+		stats.EntriesNew++
+	}
+	return entries, stats
+}
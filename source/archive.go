@@ -0,0 +1,114 @@
+package source
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/discovery"
+)
+
+// memberSep joins an archive path and a member name in cache keys.
+const memberSep = "!/"
+
+// Log is one log file or archive member to parse, already decompressed.
+type Log struct {
+	io.Reader
+	// Name is the file path with any compression suffix removed, so it ends in
+	// .jsonl. Archive members are named as if extracted beside the archive, so
+	// projects and sessions are derived from their path as for plain files.
+	Name    string
+	ModTime time.Time
+}
+
+// MemberKey returns the cache key of an archive member.
+func MemberKey(archive, member string) string {
+	return archive + memberSep + member
+}
+
+// SplitMember splits an archive member's cache key into the archive path and
+// member name.
+func SplitMember(key string) (archive, member string, ok bool) {
+	return strings.Cut(key, memberSep)
+}
+
+// IsArchive reports whether path is a tar archive, optionally compressed.
+func IsArchive(path string) bool {
+	for _, suffix := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decompress wraps r in the decompressor matching name's suffix.
+func decompress(r io.Reader, name string) (io.Reader, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return gzip.NewReader(r)
+	case strings.HasSuffix(name, ".bz2"):
+		return bzip2.NewReader(r), nil
+	}
+	return r, nil
+}
+
+// logName strips the compression suffix from a JSONL log's name.
+func logName(name string) string {
+	for _, suffix := range []string{".gz", ".bz2"} {
+		if trimmed, ok := strings.CutSuffix(name, suffix); ok {
+			return trimmed
+		}
+	}
+	return name
+}
+
+// Walk calls fn for the log at path: the file itself, decompressed, or each
+// JSONL member of a tar archive. key identifies the log in the cache and is
+// the path itself or the member key.
+func Walk(path string, fn func(key string, log Log) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := decompress(f, path)
+	if err != nil {
+		return err
+	}
+
+	if !IsArchive(path) {
+		return fn(path, Log{Reader: r, Name: logName(path), ModTime: info.ModTime()})
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || IsArchive(hdr.Name) || !discovery.IsLog(hdr.Name) {
+			continue
+		}
+		mr, err := decompress(tr, hdr.Name)
+		if err != nil {
+			return err
+		}
+		member := strings.TrimPrefix(hdr.Name, "./")
+		name := logName(filepath.Join(filepath.Dir(path), filepath.FromSlash(member)))
+		if err := fn(MemberKey(path, member), Log{Reader: mr, Name: name, ModTime: hdr.ModTime}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package source
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/parser"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// CodexHome returns the OpenAI Codex CLI home: CODEX_HOME or ~/.codex.
+func CodexHome() string {
+	if dir := os.Getenv("CODEX_HOME"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".codex")
+}
+
+// codex reads the rollout JSONL files the Codex CLI writes under
+// <home>/sessions/YYYY/MM/DD/.
+type codex struct {
+	home string
+}
+
+// NewCodex returns the Codex CLI source for a Codex home directory.
+func NewCodex(home string) Source {
+	return &codex{home: absDirs([]string{home})[0]}
+}
+
+func (c *codex) Name() string   { return Codex }
+func (c *codex) Dirs() []string { return []string{filepath.Join(c.home, "sessions")} }
+
+// codexLine is the subset of a rollout line needed to account for a turn.
+type codexLine struct {
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Payload   struct {
+		Type       string `json:"type"`
+		ID         string `json:"id"`
+		Cwd        string `json:"cwd"`
+		CLIVersion string `json:"cli_version"`
+		Model      string `json:"model"`
+		Name       string `json:"name"`
+		Git        struct {
+			Branch string `json:"branch"`
+		} `json:"git"`
+		Info *struct {
+			Total *codexUsage `json:"total_token_usage"`
+			Last  *codexUsage `json:"last_token_usage"`
+		} `json:"info"`
+	} `json:"payload"`
+}
+
+type codexUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	TotalTokens       int `json:"total_tokens"`
+}
+
+func (u *codexUsage) sub(prev codexUsage) codexUsage {
+	return codexUsage{
+		InputTokens:       u.InputTokens - prev.InputTokens,
+		CachedInputTokens: u.CachedInputTokens - prev.CachedInputTokens,
+		OutputTokens:      u.OutputTokens - prev.OutputTokens,
+		TotalTokens:       u.TotalTokens - prev.TotalTokens,
+	}
+}
+
+// Parse turns each token_count event into an entry. Events carry the running
+// session total, so each entry is the increase since the previous event and is
+// keyed by session, turn number and running total: repeated events and rollouts
+// replayed into a resumed session dedupe against the original, while distinct
+// turns that happen to reach the same total stay apart.
+func (c *codex) Parse(log Log) ([]usage.Entry, parser.FileStats) {
+	var entries []usage.Entry
+	seen := make(map[string]bool)
+	var stats parser.FileStats
+
+	session := strings.TrimSuffix(filepath.Base(log.Name), ".jsonl")
+	var cwd, branch, version, model string
+	var prev codexUsage
+	var turns int
+	var tools []string
+
+	lines := parser.NewLineReader(log)
+	for lines.Next() {
+		stats.LinesRead++
+		var line codexLine
+		if lines.TooLong() || json.Unmarshal(lines.Bytes(), &line) != nil {
+			continue
+		}
+		stats.LinesParsed++
+		p := &line.Payload
+		switch line.Type {
+		case "session_meta":
+			if p.ID != "" {
+				session = p.ID
+			}
+			cwd, branch, version = p.Cwd, p.Git.Branch, p.CLIVersion
+			continue
+		case "turn_context":
+			if p.Model != "" {
+				model = p.Model
+			}
+			if p.Cwd != "" {
+				cwd = p.Cwd
+			}
+			continue
+		case "response_item":
+			if (p.Type == "function_call" || p.Type == "custom_tool_call") && p.Name != "" {
+				tools = append(tools, p.Name)
+			}
+			continue
+		case "event_msg":
+			if p.Type != "token_count" || p.Info == nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, line.Timestamp)
+		if err != nil {
+			continue
+		}
+		var turn codexUsage
+		var key string
+		switch {
+		case p.Info.Total != nil:
+			turn = p.Info.Total.sub(prev)
+			if turn.TotalTokens <= 0 {
+				continue
+			}
+			prev = *p.Info.Total
+			turns++
+			key = Codex + ":" + session + ":" + strconv.Itoa(turns) + ":" + strconv.Itoa(p.Info.Total.TotalTokens)
+		case p.Info.Last != nil:
+			turn = *p.Info.Last
+			key = Codex + ":" + session + ":" + line.Timestamp
+		default:
+			continue
+		}
+		if turn.InputTokens == 0 && turn.OutputTokens == 0 {
+			continue
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		m := model
+		if m == "" {
+			m = "unknown"
+		}
+		// Codex counts cached tokens as part of the input
+		entries = append(entries, usage.Entry{
+			Key:             key,
+			Date:            t.UTC().Format("2006-01-02"),
+			Timestamp:       t.Unix(),
+			Model:           m,
+			Project:         projectName(cwd),
+			Root:            c.home,
+			Branch:          branch,
+			Cwd:             cwd,
+			Session:         session,
+			Version:         version,
+			Tools:           tools,
+			InputTokens:     turn.InputTokens - turn.CachedInputTokens,
+			OutputTokens:    turn.OutputTokens,
+			CacheReadTokens: turn.CachedInputTokens,
+		})
+		tools = nil
+		stats.EntriesNew++
+	}
+	return entries, stats
+}
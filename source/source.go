@@ -0,0 +1,198 @@
+// Package source abstracts the tools whose logs are read: each source knows
+// where its logs live and how to parse them into usage entries.
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/parser"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// Source names, stored in usage.Entry.Source.
+const (
+	ClaudeCode   = "claude-code"
+	Codex        = "codex"
+	AnthropicAPI = "anthropic-api"
+)
+
+// Source discovers and parses the JSONL logs of one tool.
+type Source interface {
+	// Name identifies the source in reports.
+	Name() string
+	// Dirs returns the directories holding the source's logs.
+	Dirs() []string
+	// Parse reads one log into entries in log order. A request may appear
+	// more than once, leaving the copy counted to the dedup strategy.
+	Parse(log Log) ([]usage.Entry, parser.FileStats)
+}
+
+// Set is the sources read together into one dataset.
+type Set []Source
+
+// Dirs returns the log directories of every source.
+func (s Set) Dirs() []string {
+	var dirs []string
+	for _, src := range s {
+		dirs = append(dirs, src.Dirs()...)
+	}
+	return dirs
+}
+
+// Names returns the name of every source.
+func (s Set) Names() []string {
+	names := make([]string, len(s))
+	for i, src := range s {
+		names[i] = src.Name()
+	}
+	return names
+}
+
+// For returns the source owning path: the one with the deepest directory
+// containing it, or nil.
+func (s Set) For(path string) Source {
+	var owner Source
+	best := -1
+	for _, src := range s {
+		for _, dir := range src.Dirs() {
+			if len(dir) > best && strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				owner, best = src, len(dir)
+			}
+		}
+	}
+	return owner
+}
+
+// File is the entries parsed from one log file or archive member.
+type File struct {
+	Key     string // the file path, or the member key for archive members
+	Entries []usage.Entry
+	Stats   parser.FileStats
+}
+
+// ParseFile parses the log at path, or every log in it if it is an archive,
+// with the source owning it and records the source on each entry. Files
+// outside every source directory yield nothing. A read error keeps whatever
+// was parsed before it.
+func (s Set) ParseFile(path string) []File {
+	src := s.For(path)
+	if src == nil {
+		return nil
+	}
+	var files []File
+	_ = Walk(path, func(key string, log Log) error {
+		entries, stats := src.Parse(log)
+		for i := range entries {
+			entries[i].Source = src.Name()
+		}
+		files = append(files, File{Key: key, Entries: entries, Stats: stats})
+		return nil
+	})
+	return files
+}
+
+// Defaults returns the Claude Code source for configDirs and an Anthropic API
+// source for apiDirs (or CCUSAGE_API_LOG_DIR when apiDirs is empty). Codex is
+// read only when asked for, through Select or NewCodex.
+func Defaults(configDirs, apiDirs []string) Set {
+	set := Set{NewClaudeCode(discovery.ConfigDirs(configDirs))}
+	if apiDirs = apiLogDirs(apiDirs); len(apiDirs) > 0 {
+		set = append(set, NewAnthropicAPI(apiDirs))
+	}
+	return set
+}
+
+// Select returns the sources named in names: claude-code, codex, anthropic-api
+// or all. No names gives Defaults, and all adds Codex to them.
+func Select(names, configDirs, apiDirs []string) (Set, error) {
+	if len(names) == 0 {
+		return Defaults(configDirs, apiDirs), nil
+	}
+	apiDirs = apiLogDirs(apiDirs)
+	want := make(map[string]bool)
+	for _, name := range names {
+		switch name {
+		case ClaudeCode, Codex:
+			want[name] = true
+		case AnthropicAPI:
+			if len(apiDirs) == 0 {
+				return nil, fmt.Errorf("source %s needs --api-log-dir or CCUSAGE_API_LOG_DIR", AnthropicAPI)
+			}
+			want[name] = true
+		case "all":
+			want[ClaudeCode], want[Codex] = true, true
+			want[AnthropicAPI] = want[AnthropicAPI] || len(apiDirs) > 0
+		default:
+			return nil, fmt.Errorf("unknown source %q", name)
+		}
+	}
+	var set Set
+	if want[ClaudeCode] {
+		set = append(set, NewClaudeCode(discovery.ConfigDirs(configDirs)))
+	}
+	if want[Codex] {
+		set = append(set, NewCodex(CodexHome()))
+	}
+	if want[AnthropicAPI] {
+		set = append(set, NewAnthropicAPI(apiDirs))
+	}
+	return set, nil
+}
+
+// apiLogDirs returns dirs, or CCUSAGE_API_LOG_DIR when dirs is empty.
+func apiLogDirs(dirs []string) []string {
+	if len(dirs) == 0 {
+		dirs = discovery.SplitDirList(os.Getenv("CCUSAGE_API_LOG_DIR"))
+	}
+	return dirs
+}
+
+// absDirs makes dirs absolute so paths found under them match For.
+func absDirs(dirs []string) []string {
+	out := make([]string, len(dirs))
+	for i, d := range dirs {
+		if abs, err := filepath.Abs(d); err == nil {
+			d = abs
+		}
+		out[i] = d
+	}
+	return out
+}
+
+// claudeCode reads Claude Code transcripts under each config directory's
+// projects/ directory.
+type claudeCode struct {
+	projectDirs []string
+}
+
+// NewClaudeCode returns the Claude Code source for the given config directories.
+func NewClaudeCode(configDirs []string) Source {
+	return &claudeCode{projectDirs: discovery.ProjectDirs(configDirs)}
+}
+
+func (c *claudeCode) Name() string   { return ClaudeCode }
+func (c *claudeCode) Dirs() []string { return c.projectDirs }
+
+func (c *claudeCode) Parse(log Log) ([]usage.Entry, parser.FileStats) {
+	return parser.Parse(log, log.Name)
+}
+
+// projectName turns a working directory into a project name the way Claude
+// Code names its projects/ directories, so the same checkout groups together
+// across sources.
+func projectName(cwd string) string {
+	if cwd == "" {
+		return ""
+	}
+	b := []byte(cwd)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			b[i] = '-'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/parser"
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// statuslinePayload is the JSON Claude Code pipes to a statusLine command.
+type statuslinePayload struct {
+	SessionID      string `json:"session_id"`
+	TranscriptPath string `json:"transcript_path"`
+	Cwd            string `json:"cwd"`
+	Model          struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	} `json:"model"`
+}
+
+// runStatusline prints a single line with the current session's cost, today's
+// cost and the active billing block's cost. It runs on every prompt, so it only
+// uses the warm discovery path: directories in the cache manifest are stat'ed and
+// changed files reparsed, but the tree is never fully walked, and only the
+// session's files and the last two days are deduplicated. Without a warm cache
+// only the session's own transcript is read before printing; the cache is
+// built afterwards so the next prompt is warm.
+func runStatusline(args []string) {
+	fs := flag.NewFlagSet("statusline", flag.ExitOnError)
+	dedup := addDedupFlag(fs)
+	costModeName := addCostModeFlag(fs)
+	srcFlags := addSourceFlags(fs)
+	_ = fs.Parse(args)
+	mode := costMode(*costModeName)
+	strategy := dedupStrategy(*dedup)
+
+	var payload statuslinePayload
+	_ = json.NewDecoder(os.Stdin).Decode(&payload)
+
+	now := time.Now().UTC()
+	cache, cacheValid := store.Open(false, false)
+	cache.SetDedup(strategy)
+	sources := srcFlags.sources()
+
+	var parts []string
+	if payload.Model.DisplayName != "" {
+		parts = append(parts, payload.Model.DisplayName)
+	}
+
+	if !cacheValid || !cache.Covers(sources.Dirs()) {
+		// Cold cache: only the transcript itself is cheap enough to read
+		var sessionCost float64
+		if payload.TranscriptPath != "" {
+			entries, _ := parser.ParseFile(payload.TranscriptPath)
+			deduped := make(map[string]*usage.Entry, len(entries))
+			var stats usage.MergeStats
+			for i := range entries {
+				cache.Strategy.Merge(deduped, &entries[i], &stats)
+			}
+			for _, e := range deduped {
+				sessionCost += mode.EntryCost(e, pricing.Default)
+			}
+		}
+		parts = append(parts, "session "+render.FormatDollars(sessionCost), "today –", "block –")
+		fmt.Println(strings.Join(parts, " | "))
+
+		// Build the cache now, after printing, so later prompts take the warm path
+		if _, err := cache.Refresh(context.Background(), sources, cacheValid); err == nil {
+			_ = cache.Save()
+		}
+		return
+	}
+
+	files, dStats := discovery.Warm(&cache.Manifest, maps.Keys(cache.Files))
+	changes, cStats, _ := cache.Update(context.Background(), sources, files, true)
+	cache.RefreshRollups(changes, &cStats)
+
+	// Session: the transcript plus any subagent transcripts stored beside it
+	transcript := payload.TranscriptPath
+	if transcript == "" && payload.SessionID != "" {
+		for path := range cache.Files {
+			if filepath.Base(path) == payload.SessionID+".jsonl" {
+				transcript = path
+				break
+			}
+		}
+	}
+	var sessionPaths []string
+	if transcript != "" {
+		sessionPaths = append(sessionPaths, transcript)
+		_ = filepath.WalkDir(strings.TrimSuffix(transcript, ".jsonl"), func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				sessionPaths = append(sessionPaths, path)
+			}
+			return nil
+		})
+	}
+	var sessionStats usage.MergeStats
+	sessionEntries := cache.DedupFiles(sessionPaths, &sessionStats)
+	var sessionCost float64
+	for _, e := range sessionEntries {
+		sessionCost += mode.EntryCost(e, pricing.Default)
+	}
+	parts = append(parts, "session "+render.FormatDollars(sessionCost))
+
+	var todayCost float64
+	if r := cache.Rollups[now.Format("2006-01-02")]; r != nil {
+		todayCost = mode.Cost(&usage.DayUsage{Models: r.Models}, pricing.Default)
+	}
+	parts = append(parts, "today "+render.FormatDollars(todayCost))
+
+	if b := currentBlock(cache, now); b != nil {
+		parts = append(parts, fmt.Sprintf("block %s (%s left)",
+			render.FormatDollars(blockCost(b, pricing.Default, mode)), formatRemaining(b.End.Sub(now))))
+	} else {
+		parts = append(parts, "block –")
+	}
+
+	fmt.Println(strings.Join(parts, " | "))
+
+	if changes.Dirty() || dStats.DirsChanged > 0 {
+		_ = cache.Save()
+	}
+}
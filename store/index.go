@@ -0,0 +1,154 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// EntryRef locates a cached entry: the Index-th entry of Files[Path].
+type EntryRef struct {
+	Path  string
+	Index int
+}
+
+// entry returns the entry ref points to.
+func (c *Cache) entry(ref EntryRef) *usage.Entry {
+	return &c.Files[ref.Path].Entries[ref.Index]
+}
+
+// reindex rebuilds the key and day indexes from every cached file.
+func (c *Cache) reindex() {
+	c.Keys = make(map[string][]EntryRef)
+	c.Days = make(map[string]map[string]bool)
+	c.Duplicated = 0
+	for _, path := range c.SortedPaths() {
+		c.index(path)
+	}
+}
+
+// index adds the entries of Files[path] to the key and day indexes.
+func (c *Cache) index(path string) {
+	before := make(map[string]int) // copies of each touched key before this file
+	for i := range c.Files[path].Entries {
+		e := &c.Files[path].Entries[i]
+		key := c.Strategy.Key(e)
+		if _, ok := before[key]; !ok {
+			before[key] = len(c.Keys[key])
+		}
+		c.Keys[key] = append(c.Keys[key], EntryRef{path, i})
+		if c.Days[e.Date] == nil {
+			c.Days[e.Date] = make(map[string]bool)
+		}
+		c.Days[e.Date][key] = true
+	}
+	for key, n := range before {
+		refs := c.Keys[key]
+		// Keep copies in merge order: by path, then position in the file
+		if n > 0 {
+			sort.Slice(refs, func(a, b int) bool {
+				if refs[a].Path != refs[b].Path {
+					return refs[a].Path < refs[b].Path
+				}
+				return refs[a].Index < refs[b].Index
+			})
+		}
+		if n < 2 && len(refs) >= 2 {
+			c.Duplicated++
+		}
+	}
+}
+
+// validIndex reports whether every ref in Keys points at a cached entry with
+// that key, so a stale or damaged cache is reindexed rather than trusted.
+func (c *Cache) validIndex() bool {
+	for key, refs := range c.Keys {
+		for _, ref := range refs {
+			fc := c.Files[ref.Path]
+			if fc == nil || ref.Index < 0 || ref.Index >= len(fc.Entries) || c.Strategy.Key(&fc.Entries[ref.Index]) != key {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unindex removes the entries of Files[path] from the key and day indexes.
+// It must run before the file's entries are replaced or deleted.
+func (c *Cache) unindex(path string) {
+	for i := range c.Files[path].Entries {
+		e := &c.Files[path].Entries[i]
+		key := c.Strategy.Key(e)
+		refs := c.Keys[key]
+		if len(refs) == 0 {
+			// Every copy in this file was already removed
+			continue
+		}
+		kept := refs[:0]
+		for _, ref := range refs {
+			if ref.Path != path {
+				kept = append(kept, ref)
+			}
+		}
+		if len(refs) >= 2 && len(kept) < 2 {
+			c.Duplicated--
+		}
+		if len(kept) == 0 {
+			delete(c.Keys, key)
+		} else {
+			c.Keys[key] = kept
+		}
+	}
+	// A key stays listed on a day while another copy is dated that day
+	for i := range c.Files[path].Entries {
+		e := &c.Files[path].Entries[i]
+		key := c.Strategy.Key(e)
+		onDay := false
+		for _, ref := range c.Keys[key] {
+			onDay = onDay || c.entry(ref).Date == e.Date
+		}
+		if !onDay {
+			delete(c.Days[e.Date], key)
+			if len(c.Days[e.Date]) == 0 {
+				delete(c.Days, e.Date)
+			}
+		}
+	}
+}
+
+// winners merges every copy of keys with the cache's strategy.
+func (c *Cache) winners(keys map[string]bool, stats *usage.MergeStats) map[string]*usage.Entry {
+	all := make(map[string]*usage.Entry, len(keys))
+	for key := range keys {
+		for _, ref := range c.Keys[key] {
+			c.Strategy.Merge(all, c.entry(ref), stats)
+		}
+	}
+	return all
+}
+
+// DedupDays is Dedup limited to the requests with a copy on one of dates. It
+// reads the indexes, so RefreshRollups must have run.
+func (c *Cache) DedupDays(dates []string, stats *usage.MergeStats) map[string]*usage.Entry {
+	keys := make(map[string]bool)
+	for _, d := range dates {
+		for key := range c.Days[d] {
+			keys[key] = true
+		}
+	}
+	return c.winners(keys, stats)
+}
+
+// DedupFiles is Dedup limited to the requests with a copy in one of paths. It
+// reads the indexes, so RefreshRollups must have run.
+func (c *Cache) DedupFiles(paths []string, stats *usage.MergeStats) map[string]*usage.Entry {
+	keys := make(map[string]bool)
+	for _, path := range paths {
+		if fc := c.Files[path]; fc != nil {
+			for i := range fc.Entries {
+				keys[c.Strategy.Key(&fc.Entries[i])] = true
+			}
+		}
+	}
+	return c.winners(keys, stats)
+}
@@ -0,0 +1,485 @@
+// Package store persists parsed entries, the discovery manifest and daily
+// rollups in a binary cache so warm runs only reparse changed files.
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// Version is the cache format version; caches written by other versions are
+// discarded and rebuilt.
+const Version = 19
+
+var cacheMagic = [4]byte{'C', 'C', 'U', 'G'}
+
+// FileEntry is the parsed entries of one file, valid while its mtime and size
+// are unchanged.
+type FileEntry struct {
+	ModTime int64
+	Size    int64
+	Entries []usage.Entry
+}
+
+// Cache holds the parsed entries of every file, the directory manifest used for
+// discovery and the daily rollups.
+type Cache struct {
+	Version  int
+	Timezone string
+	Files    map[string]*FileEntry
+	discovery.Manifest
+	Rollups map[string]*usage.DayRollup
+	// Strategy is the dedup strategy Dedup and the rollups use.
+	Strategy usage.Dedup
+	// Keys locates every copy of each dedup key, and Days lists the keys with
+	// a copy on each day, so RefreshRollups only visits what a change touches.
+	// Duplicated counts the keys with more than one copy.
+	Keys       map[string][]EntryRef
+	Days       map[string]map[string]bool
+	Duplicated int
+}
+
+// Encoded types for string-interned binary cache
+type encodedCache struct {
+	StringTable  []string
+	Files        map[string]*encodedFileEntry
+	Dirs         map[string]int64
+	Roots        []string
+	LastFullWalk time.Time
+	Rollups      map[string]*usage.DayRollup
+	Strategy     string
+	Keys         map[string][]encodedRef
+	Days         map[string][]string
+	Duplicated   int
+}
+
+type encodedRef struct {
+	PathIdx int
+	Index   int
+}
+
+type encodedFileEntry struct {
+	ModTime int64
+	Size    int64
+	Entries []encodedEntry
+}
+
+type encodedEntry struct {
+	Key                 string
+	SourceIdx           int
+	DateIdx             int
+	Timestamp           int64
+	ModelIdx            int
+	ProjectIdx          int
+	RootIdx             int
+	BranchIdx           int
+	CwdIdx              int
+	SessionIdx          int
+	AgentIdx            int
+	Sidechain           bool
+	VersionIdx          int
+	ToolIdx             []int
+	InputTokens         int
+	OutputTokens        int
+	CacheCreationTokens int
+	CacheWrite1hTokens  int
+	CacheReadTokens     int
+	WebSearchRequests   int
+	LoggedCostUSD       float64
+	HasLoggedCost       bool
+}
+
+// Dir returns the directory holding the cache.
+func Dir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "ccusage")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "ccusage")
+}
+
+// Path returns the path of the cache file.
+func Path() string {
+	return filepath.Join(Dir(), "cache.bin")
+}
+
+func legacyPath() string {
+	return filepath.Join(Dir(), "cache.json")
+}
+
+func localTimezone() string {
+	return "UTC"
+}
+
+// loadLegacyJSON tries to read the old JSON cache format and migrate it
+func loadLegacyJSON() *Cache {
+	data, err := os.ReadFile(legacyPath())
+	if err != nil {
+		return nil
+	}
+	type jsonFileEntry struct {
+		ModTime int64          `json:"mtime"`
+		Size    int64          `json:"size"`
+		Entries []*usage.Entry `json:"entries"`
+	}
+	type jsonCacheFile struct {
+		Version      int                       `json:"version"`
+		Timezone     string                    `json:"timezone"`
+		Files        map[string]*jsonFileEntry `json:"files"`
+		Dirs         map[string]int64          `json:"dirs,omitempty"`
+		LastFullWalk time.Time                 `json:"last_full_walk,omitempty"`
+	}
+	var legacy jsonCacheFile
+	if json.Unmarshal(data, &legacy) != nil {
+		return nil
+	}
+	cache := &Cache{
+		Version:  legacy.Version,
+		Timezone: legacy.Timezone,
+		Files:    make(map[string]*FileEntry, len(legacy.Files)),
+		Manifest: discovery.Manifest{Dirs: legacy.Dirs, LastFullWalk: legacy.LastFullWalk},
+	}
+	for path, fe := range legacy.Files {
+		entries := make([]usage.Entry, len(fe.Entries))
+		for i, e := range fe.Entries {
+			entries[i] = *e
+		}
+		cache.Files[path] = &FileEntry{
+			ModTime: fe.ModTime,
+			Size:    fe.Size,
+			Entries: entries,
+		}
+	}
+	// Rebuild the indexes and rollups rather than follow a ref that points
+	// nowhere
+	if cache.Keys != nil && !cache.validIndex() {
+		cache.Keys, cache.Days, cache.Rollups = nil, nil, nil
+	}
+	return cache
+}
+
+func load() *Cache {
+	data, err := os.ReadFile(Path())
+	if err != nil {
+		return loadLegacyJSON()
+	}
+
+	// Verify binary header: magic + version
+	if len(data) < 8 {
+		return loadLegacyJSON()
+	}
+	if data[0] != cacheMagic[0] || data[1] != cacheMagic[1] || data[2] != cacheMagic[2] || data[3] != cacheMagic[3] {
+		return loadLegacyJSON()
+	}
+	version := binary.LittleEndian.Uint32(data[4:8])
+	if version != Version {
+		return nil
+	}
+
+	// Decode gob payload
+	var encoded encodedCache
+	if err := gob.NewDecoder(bytes.NewReader(data[8:])).Decode(&encoded); err != nil {
+		return nil
+	}
+
+	// De-intern strings
+	cache := &Cache{
+		Version:  int(version),
+		Timezone: "",
+		Files:    make(map[string]*FileEntry, len(encoded.Files)),
+		Manifest: discovery.Manifest{
+			Dirs:         encoded.Dirs,
+			Roots:        encoded.Roots,
+			LastFullWalk: encoded.LastFullWalk,
+		},
+		Rollups:    encoded.Rollups,
+		Strategy:   usage.Dedup(encoded.Strategy),
+		Duplicated: encoded.Duplicated,
+	}
+	if len(encoded.StringTable) > 0 {
+		cache.Timezone = encoded.StringTable[0]
+	}
+	if encoded.Keys != nil {
+		cache.Keys = make(map[string][]EntryRef, len(encoded.Keys))
+		for key, refs := range encoded.Keys {
+			decoded := make([]EntryRef, len(refs))
+			for i, ref := range refs {
+				if ref.PathIdx < 0 || ref.PathIdx >= len(encoded.StringTable) {
+					return nil
+				}
+				decoded[i] = EntryRef{Path: encoded.StringTable[ref.PathIdx], Index: ref.Index}
+			}
+			cache.Keys[key] = decoded
+		}
+		cache.Days = make(map[string]map[string]bool, len(encoded.Days))
+		for date, keys := range encoded.Days {
+			cache.Days[date] = make(map[string]bool, len(keys))
+			for _, key := range keys {
+				cache.Days[date][key] = true
+			}
+		}
+	}
+
+	for path, fe := range encoded.Files {
+		entries := make([]usage.Entry, len(fe.Entries))
+		for i, ee := range fe.Entries {
+			dateStr := ""
+			if ee.DateIdx >= 0 && ee.DateIdx < len(encoded.StringTable) {
+				dateStr = encoded.StringTable[ee.DateIdx]
+			}
+			modelStr := ""
+			if ee.ModelIdx >= 0 && ee.ModelIdx < len(encoded.StringTable) {
+				modelStr = encoded.StringTable[ee.ModelIdx]
+			}
+			projectStr := ""
+			if ee.ProjectIdx >= 0 && ee.ProjectIdx < len(encoded.StringTable) {
+				projectStr = encoded.StringTable[ee.ProjectIdx]
+			}
+			sourceStr := ""
+			if ee.SourceIdx >= 0 && ee.SourceIdx < len(encoded.StringTable) {
+				sourceStr = encoded.StringTable[ee.SourceIdx]
+			}
+			rootStr := ""
+			if ee.RootIdx >= 0 && ee.RootIdx < len(encoded.StringTable) {
+				rootStr = encoded.StringTable[ee.RootIdx]
+			}
+			branchStr := ""
+			if ee.BranchIdx >= 0 && ee.BranchIdx < len(encoded.StringTable) {
+				branchStr = encoded.StringTable[ee.BranchIdx]
+			}
+			cwdStr := ""
+			if ee.CwdIdx >= 0 && ee.CwdIdx < len(encoded.StringTable) {
+				cwdStr = encoded.StringTable[ee.CwdIdx]
+			}
+			sessionStr := ""
+			if ee.SessionIdx >= 0 && ee.SessionIdx < len(encoded.StringTable) {
+				sessionStr = encoded.StringTable[ee.SessionIdx]
+			}
+			agentStr := ""
+			if ee.AgentIdx >= 0 && ee.AgentIdx < len(encoded.StringTable) {
+				agentStr = encoded.StringTable[ee.AgentIdx]
+			}
+			versionStr := ""
+			if ee.VersionIdx >= 0 && ee.VersionIdx < len(encoded.StringTable) {
+				versionStr = encoded.StringTable[ee.VersionIdx]
+			}
+			var tools []string
+			for _, idx := range ee.ToolIdx {
+				if idx >= 0 && idx < len(encoded.StringTable) {
+					tools = append(tools, encoded.StringTable[idx])
+				}
+			}
+			entries[i] = usage.Entry{
+				Key:                 ee.Key,
+				Source:              sourceStr,
+				Date:                dateStr,
+				Timestamp:           ee.Timestamp,
+				Model:               modelStr,
+				Project:             projectStr,
+				Root:                rootStr,
+				Branch:              branchStr,
+				Cwd:                 cwdStr,
+				Session:             sessionStr,
+				Agent:               agentStr,
+				Sidechain:           ee.Sidechain,
+				Version:             versionStr,
+				Tools:               tools,
+				InputTokens:         ee.InputTokens,
+				OutputTokens:        ee.OutputTokens,
+				CacheCreationTokens: ee.CacheCreationTokens,
+				CacheWrite1hTokens:  ee.CacheWrite1hTokens,
+				CacheReadTokens:     ee.CacheReadTokens,
+				WebSearchRequests:   ee.WebSearchRequests,
+				LoggedCostUSD:       ee.LoggedCostUSD,
+				HasLoggedCost:       ee.HasLoggedCost,
+			}
+		}
+		cache.Files[path] = &FileEntry{
+			ModTime: fe.ModTime,
+			Size:    fe.Size,
+			Entries: entries,
+		}
+	}
+	// Rebuild the indexes and rollups rather than follow a ref that points
+	// nowhere
+	if cache.Keys != nil && !cache.validIndex() {
+		cache.Keys, cache.Days, cache.Rollups = nil, nil, nil
+	}
+	return cache
+}
+
+// Save writes the cache atomically.
+func (c *Cache) Save() error {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Build string table for interning
+	stringIndex := make(map[string]int)
+	var stringTable []string
+	intern := func(s string) int {
+		if idx, ok := stringIndex[s]; ok {
+			return idx
+		}
+		idx := len(stringTable)
+		stringTable = append(stringTable, s)
+		stringIndex[s] = idx
+		return idx
+	}
+
+	// Timezone is always index 0
+	intern(c.Timezone)
+
+	// Build encoded structure
+	encoded := encodedCache{
+		Files:        make(map[string]*encodedFileEntry, len(c.Files)),
+		Dirs:         c.Dirs,
+		Roots:        c.Roots,
+		LastFullWalk: c.LastFullWalk,
+		Rollups:      c.Rollups,
+		Strategy:     string(c.Strategy),
+		Duplicated:   c.Duplicated,
+	}
+	if c.Keys != nil {
+		encoded.Keys = make(map[string][]encodedRef, len(c.Keys))
+		for key, refs := range c.Keys {
+			out := make([]encodedRef, len(refs))
+			for i, ref := range refs {
+				out[i] = encodedRef{PathIdx: intern(ref.Path), Index: ref.Index}
+			}
+			encoded.Keys[key] = out
+		}
+		encoded.Days = make(map[string][]string, len(c.Days))
+		for date, keys := range c.Days {
+			for key := range keys {
+				encoded.Days[date] = append(encoded.Days[date], key)
+			}
+		}
+	}
+	for path, fe := range c.Files {
+		entries := make([]encodedEntry, len(fe.Entries))
+		for i, e := range fe.Entries {
+			var toolIdx []int
+			for _, tool := range e.Tools {
+				toolIdx = append(toolIdx, intern(tool))
+			}
+			entries[i] = encodedEntry{
+				Key:                 e.Key,
+				SourceIdx:           intern(e.Source),
+				DateIdx:             intern(e.Date),
+				Timestamp:           e.Timestamp,
+				ModelIdx:            intern(e.Model),
+				ProjectIdx:          intern(e.Project),
+				RootIdx:             intern(e.Root),
+				BranchIdx:           intern(e.Branch),
+				CwdIdx:              intern(e.Cwd),
+				SessionIdx:          intern(e.Session),
+				AgentIdx:            intern(e.Agent),
+				Sidechain:           e.Sidechain,
+				VersionIdx:          intern(e.Version),
+				ToolIdx:             toolIdx,
+				InputTokens:         e.InputTokens,
+				OutputTokens:        e.OutputTokens,
+				CacheCreationTokens: e.CacheCreationTokens,
+				CacheWrite1hTokens:  e.CacheWrite1hTokens,
+				CacheReadTokens:     e.CacheReadTokens,
+				WebSearchRequests:   e.WebSearchRequests,
+				LoggedCostUSD:       e.LoggedCostUSD,
+				HasLoggedCost:       e.HasLoggedCost,
+			}
+		}
+		encoded.Files[path] = &encodedFileEntry{
+			ModTime: fe.ModTime,
+			Size:    fe.Size,
+			Entries: entries,
+		}
+	}
+	encoded.StringTable = stringTable
+
+	// Encode: magic + version + gob payload
+	var buf bytes.Buffer
+	buf.Write(cacheMagic[:])
+	var versionBytes [4]byte
+	binary.LittleEndian.PutUint32(versionBytes[:], uint32(Version))
+	buf.Write(versionBytes[:])
+
+	if err := gob.NewEncoder(&buf).Encode(encoded); err != nil {
+		return err
+	}
+
+	// Write to a unique temp file so concurrent runs (e.g. statusline invocations
+	// from several sessions) never interleave writes before the atomic rename
+	tmp, err := os.CreateTemp(dir, "c.bin.*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	// Remove legacy JSON cache if it exists
+	os.Remove(legacyPath())
+
+	return os.Rename(tmp.Name(), Path())
+}
+
+// Open loads the cache unless disabled and reports whether it is usable as-is.
+// An unusable cache is replaced with an empty one for the current version.
+func Open(noCache, clearCache bool) (*Cache, bool) {
+	if clearCache {
+		os.Remove(Path())
+		os.Remove(legacyPath())
+	}
+	var cache *Cache
+	if !noCache && !clearCache {
+		cache = load()
+	}
+	cacheValid := cache != nil &&
+		cache.Version == Version &&
+		cache.Timezone == localTimezone()
+	if !cacheValid {
+		cache = New()
+	}
+	return cache, cacheValid
+}
+
+// New returns an empty cache for the current version, e.g. to hold entries
+// that should not be persisted.
+func New() *Cache {
+	return &Cache{
+		Version:  Version,
+		Timezone: localTimezone(),
+		Files:    make(map[string]*FileEntry),
+		Strategy: usage.DedupMaxTokens,
+	}
+}
+
+// SetDedup switches the dedup strategy, dropping the rollups and indexes if
+// they were built with another one so the next refresh rebuilds them.
+func (c *Cache) SetDedup(d usage.Dedup) {
+	if c.Strategy != d {
+		c.Strategy = d
+		c.Rollups = nil
+		c.Keys, c.Days = nil, nil
+	}
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// writeTranscript writes a random Claude Code transcript drawing request IDs
+// from a small pool, so requests repeat within and across files, and bumps
+// its mtime so Update sees the change.
+func writeTranscript(t *testing.T, rng *rand.Rand, path string, mtime time.Time) {
+	t.Helper()
+	var b strings.Builder
+	for range rng.Intn(12) {
+		n := rng.Intn(40)
+		ts := time.Date(2026, 6, 1+rng.Intn(6), 12, rng.Intn(60), rng.Intn(60), 0, time.UTC)
+		fmt.Fprintf(&b, `{"type":"assistant","timestamp":%q,"requestId":"req_%d","message":{"id":"msg_%d","model":"claude-sonnet-4-5","usage":{"input_tokens":%d,"output_tokens":%d}}}`+"\n",
+			ts.Format(time.RFC3339), n, n%30, 1+rng.Intn(3)*100, rng.Intn(3)*10)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkRollups compares the incrementally maintained rollups and indexes with
+// ones built from scratch.
+func checkRollups(t *testing.T, c *Cache, round int) {
+	t.Helper()
+	var stats usage.MergeStats
+	want := usage.Rollup(c.Dedup(&stats))
+	if !reflect.DeepEqual(c.Rollups, want) {
+		t.Fatalf("%s round %d: incremental rollups differ from a full rebuild\ngot  %v\nwant %v", c.Strategy, round, rollupTotals(c.Rollups), rollupTotals(want))
+	}
+	keys, days, duplicated := c.Keys, c.Days, c.Duplicated
+	c.reindex()
+	if !reflect.DeepEqual(keys, c.Keys) || !reflect.DeepEqual(days, c.Days) || duplicated != c.Duplicated {
+		t.Fatalf("%s round %d: incremental indexes differ from a full reindex", c.Strategy, round)
+	}
+}
+
+func rollupTotals(rollups map[string]*usage.DayRollup) []string {
+	var out []string
+	for date, r := range rollups {
+		for model, u := range r.Models {
+			out = append(out, fmt.Sprintf("%s/%s:%d+%d", date, model, u.Input, u.Output))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestRefreshRollupsIncremental(t *testing.T) {
+	for _, d := range usage.DedupStrategies {
+		t.Run(string(d), func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			dir := t.TempDir()
+			project := filepath.Join(dir, "projects", "p")
+			if err := os.MkdirAll(project, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			sources := source.Set{source.NewClaudeCode([]string{dir})}
+			rng := rand.New(rand.NewSource(1))
+			mtime := time.Now()
+			for i := range 6 {
+				writeTranscript(t, rng, filepath.Join(project, fmt.Sprintf("s%d.jsonl", i)), mtime)
+			}
+
+			c := New()
+			c.SetDedup(d)
+			valid := false
+			entries := make(map[string]*usage.Entry)
+			for round := range 60 {
+				files, _ := filepath.Glob(filepath.Join(project, "*.jsonl"))
+				changes, stats, err := c.Update(context.Background(), sources, files, valid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.RefreshRollups(changes, &stats)
+				checkRollups(t, c, round)
+				entries = c.ApplyChanges(entries, changes, &usage.MergeStats{})
+				if want := c.Dedup(&usage.MergeStats{}); !reflect.DeepEqual(entries, want) {
+					t.Fatalf("%s round %d: ApplyChanges kept %d requests, Dedup has %d", d, round, len(entries), len(want))
+				}
+				valid = true
+
+				// Round-trip through the cache file now and then
+				if round%10 == 9 {
+					if err := c.Save(); err != nil {
+						t.Fatal(err)
+					}
+					if c = load(); c == nil || c.Keys == nil {
+						t.Fatalf("round %d: saved cache did not load with its indexes", round)
+					}
+				}
+
+				// Rewrite, add or delete a file
+				mtime = mtime.Add(time.Second)
+				name := filepath.Join(project, fmt.Sprintf("s%d.jsonl", rng.Intn(8)))
+				if _, err := os.Stat(name); err == nil && rng.Intn(4) == 0 {
+					_ = os.Remove(name)
+				} else {
+					writeTranscript(t, rng, name, mtime)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRejectsStaleIndex(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	c := New()
+	c.SetDedup(usage.DedupMaxTokens)
+	c.Files["/a.jsonl"] = &FileEntry{Entries: []usage.Entry{{Key: "m:r", Date: "2026-06-01", InputTokens: 1}}}
+	c.reindex()
+	c.Rollups = usage.Rollup(c.Dedup(&usage.MergeStats{}))
+	// Point refs past the end of the file and at a file that is gone
+	c.Keys["m:r"] = append(c.Keys["m:r"], EntryRef{"/a.jsonl", 5}, EntryRef{"/gone.jsonl", 0})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := load()
+	if loaded == nil {
+		t.Fatal("load() = nil")
+	}
+	if loaded.Keys != nil || loaded.Rollups != nil {
+		t.Errorf("load() kept an index with refs to missing entries")
+	}
+	changes, stats, err := loaded.Update(context.Background(), nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.RefreshRollups(changes, &stats)
+	if !changes.all {
+		t.Errorf("Update after a rejected index did not rebuild from scratch")
+	}
+}
+
+// A response logged twice in one file while it streamed: the default strategy
+// counts the larger copy, where releases before per-file copies counted the
+// first line of the file.
+func TestDefaultDedupWithinFile(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	project := filepath.Join(dir, "projects", "p")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"type":"assistant","timestamp":"2026-06-01T12:00:0%dZ","requestId":"req_1","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":%d,"output_tokens":%d}}}` + "\n"
+	path := filepath.Join(project, "s.jsonl")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(line, 0, 100, 1)+fmt.Sprintf(line, 1, 100, 250)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		strategy usage.Dedup
+		output   int
+	}{
+		{New().Strategy, 250},
+		{usage.DedupFirstSeen, 1},
+		{usage.DedupLastSeen, 250},
+	} {
+		c := New()
+		c.SetDedup(tc.strategy)
+		changes, stats, err := c.Update(context.Background(), source.Set{source.NewClaudeCode([]string{dir})}, []string{path}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.RefreshRollups(changes, &stats)
+		u := c.Rollups["2026-06-01"].Models["claude-sonnet-4-5"]
+		if stats.Requests != 1 || u.Input != 100 || u.Output != tc.output {
+			t.Errorf("%s: %d requests, %d+%d tokens, want 1 request, 100+%d", tc.strategy, stats.Requests, u.Input, u.Output, tc.output)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/abatilo/ccusage-go/discovery"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// Stats counts the work done bringing the cache up to date.
+type Stats struct {
+	Hits        int
+	Misses      int
+	Lines       int // lines read from reparsed files
+	DaysRebuilt int
+	KeysMerged  int // dedup keys whose copies were merged again
+	Requests    int // distinct dedup keys in the cache
+	Duplicated  int // keys with more than one copy
+	usage.MergeStats
+}
+
+// ChangeSet records which dedup keys and days were affected by files that were
+// reparsed or removed since the cache was written.
+type ChangeSet struct {
+	keys  map[string]bool
+	days  map[string]bool
+	all   bool // rollups must be rebuilt from scratch
+	dirty bool // files were reparsed or removed
+}
+
+func (c *ChangeSet) addEntries(d usage.Dedup, entries []usage.Entry) {
+	for i := range entries {
+		c.keys[d.Key(&entries[i])] = true
+		c.days[entries[i].Date] = true
+	}
+}
+
+// Dirty reports whether the cache changed and needs saving.
+func (c *ChangeSet) Dirty() bool {
+	return c.dirty || c.all || len(c.keys) > 0
+}
+
+// Update brings the cache up to date with files, reparsing only files whose
+// mtime or size changed with the source owning them, and reports which keys
+// and days changed. Archives are cached as a marker entry for the archive plus
+// one entry per member, and are reparsed as a whole. The cache is left
+// untouched if ctx is cancelled while parsing.
+func (c *Cache) Update(ctx context.Context, sources source.Set, files []string, valid bool) (*ChangeSet, Stats, error) {
+	var stats Stats
+	changes := &ChangeSet{
+		keys:  make(map[string]bool),
+		days:  make(map[string]bool),
+		all:   !valid || c.Rollups == nil || c.Keys == nil,
+		dirty: !valid,
+	}
+
+	existingFiles := make(map[string]bool)
+	members := make(map[string][]string)
+	for key := range c.Files {
+		if archive, _, ok := source.SplitMember(key); ok {
+			members[archive] = append(members[archive], key)
+		}
+	}
+
+	// Phase 1: Sequential scan — handle cache hits, collect misses
+	type cacheMiss struct {
+		path  string
+		mtime int64
+		size  int64
+	}
+	var misses []cacheMiss
+
+	for _, path := range files {
+		existingFiles[path] = true
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		mtime := fi.ModTime().UnixNano()
+		size := fi.Size()
+
+		cached, ok := c.Files[path]
+		if valid && ok && cached.ModTime == mtime && cached.Size == size {
+			stats.Hits++
+			for _, key := range members[path] {
+				existingFiles[key] = true
+			}
+		} else {
+			stats.Misses++
+			misses = append(misses, cacheMiss{path: path, mtime: mtime, size: size})
+		}
+	}
+
+	if len(misses) > 0 {
+		changes.dirty = true
+	}
+
+	// Phase 2: Concurrent parsing of cache misses
+	type fileResult struct {
+		path  string
+		mtime int64
+		size  int64
+		files []source.File
+	}
+	results := make([]fileResult, len(misses))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+
+	for i, miss := range misses {
+		wg.Add(1)
+		go func(i int, miss cacheMiss) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			results[i] = fileResult{
+				path:  miss.path,
+				mtime: miss.mtime,
+				size:  miss.size,
+				files: sources.ParseFile(miss.path),
+			}
+		}(i, miss)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, stats, err
+	}
+
+	// Phase 3: Sequential merge — update cache, record changed keys and days
+	for ri := range results {
+		r := &results[ri]
+		for _, key := range append(members[r.path], r.path) {
+			if old, ok := c.Files[key]; ok {
+				changes.addEntries(c.Strategy, old.Entries)
+				c.remove(key, changes)
+			}
+		}
+		c.Files[r.path] = &FileEntry{ModTime: r.mtime, Size: r.size}
+		for _, f := range r.files {
+			stats.Lines += f.Stats.LinesRead
+			entries := f.Entries
+			changes.addEntries(c.Strategy, entries)
+			existingFiles[f.Key] = true
+			c.Files[f.Key] = &FileEntry{
+				ModTime: r.mtime,
+				Size:    r.size,
+				Entries: entries,
+			}
+			if !changes.all {
+				c.index(f.Key)
+			}
+		}
+	}
+
+	// Cleanup deleted files
+	for path, fc := range c.Files {
+		if !existingFiles[path] {
+			changes.dirty = true
+			changes.addEntries(c.Strategy, fc.Entries)
+			c.remove(path, changes)
+		}
+	}
+
+	return changes, stats, nil
+}
+
+// remove drops a file from the cache and, unless the indexes are about to be
+// rebuilt, from the indexes.
+func (c *Cache) remove(path string, changes *ChangeSet) {
+	if !changes.all {
+		c.unindex(path)
+	}
+	delete(c.Files, path)
+}
+
+// SortedPaths returns the cached file paths in a stable order so that dedup
+// ties resolve the same way on every run.
+func (c *Cache) SortedPaths() []string {
+	paths := make([]string, 0, len(c.Files))
+	for path := range c.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Dedup merges every cached entry into a single map keyed by dedup key, using
+// the cache's strategy.
+func (c *Cache) Dedup(stats *usage.MergeStats) map[string]*usage.Entry {
+	expectedCount := 0
+	for _, fc := range c.Files {
+		expectedCount += len(fc.Entries)
+	}
+	allEntries := make(map[string]*usage.Entry, expectedCount)
+	for _, path := range c.SortedPaths() {
+		fc := c.Files[path]
+		for i := range fc.Entries {
+			c.Strategy.Merge(allEntries, &fc.Entries[i], stats)
+		}
+	}
+	return allEntries
+}
+
+// RefreshRollups recomputes the cached daily rollups for days affected by changes.
+// A changed key may have copies in other, unchanged files on other days, so those
+// days are recomputed too; winners for every key on a dirty day are resolved
+// against all copies so a day never counts a key another day already owns. The
+// key and day indexes keep this proportional to the changed entries rather
+// than the whole history.
+func (c *Cache) RefreshRollups(changes *ChangeSet, stats *Stats) {
+	defer func() {
+		stats.Requests, stats.Duplicated = len(c.Keys), c.Duplicated
+	}()
+	if changes.all {
+		c.reindex()
+		c.Rollups = usage.Rollup(c.Dedup(&stats.MergeStats))
+		stats.DaysRebuilt = len(c.Rollups)
+		stats.KeysMerged = len(c.Keys)
+		return
+	}
+	if len(changes.keys) == 0 {
+		return
+	}
+
+	dirtyDays := make(map[string]bool, len(changes.days))
+	for d := range changes.days {
+		dirtyDays[d] = true
+	}
+	for key := range changes.keys {
+		for _, ref := range c.Keys[key] {
+			dirtyDays[c.entry(ref).Date] = true
+		}
+	}
+
+	dirtyKeys := make(map[string]bool)
+	for d := range dirtyDays {
+		for key := range c.Days[d] {
+			dirtyKeys[key] = true
+		}
+	}
+
+	winners := make(map[string]*usage.Entry, len(dirtyKeys))
+	for key := range dirtyKeys {
+		for _, ref := range c.Keys[key] {
+			c.Strategy.Merge(winners, c.entry(ref), &stats.MergeStats)
+		}
+	}
+	// Winners on clean days are already counted in that day's rollup
+	for key, e := range winners {
+		if !dirtyDays[e.Date] {
+			delete(winners, key)
+		}
+	}
+
+	for d := range dirtyDays {
+		delete(c.Rollups, d)
+	}
+	for d, r := range usage.Rollup(winners) {
+		c.Rollups[d] = r
+	}
+	stats.DaysRebuilt = len(dirtyDays)
+	stats.KeysMerged = len(dirtyKeys)
+}
+
+// Refresh brings the cache up to date with the logs of every source and
+// reports whether it changed and needs saving.
+func (c *Cache) Refresh(ctx context.Context, sources source.Set, valid bool) (bool, error) {
+	_, changed, err := c.RefreshChanges(ctx, sources, valid)
+	return changed, err
+}
+
+// RefreshChanges is Refresh that also returns what changed, for callers that
+// keep deduplicated entries up to date with ApplyChanges.
+func (c *Cache) RefreshChanges(ctx context.Context, sources source.Set, valid bool) (*ChangeSet, bool, error) {
+	files, dStats := discovery.Find(sources.Dirs(), &c.Manifest, maps.Keys(c.Files))
+	changes, cStats, err := c.Update(ctx, sources, files, valid)
+	if err != nil {
+		return nil, false, err
+	}
+	c.RefreshRollups(changes, &cStats)
+	return changes, changes.Dirty() || dStats.FullWalk || dStats.DirsChanged > 0, nil
+}
+
+// ApplyChanges updates entries, an earlier Dedup result, for the requests in
+// changes and returns it, so a long-running caller merges only the changed
+// keys rather than the whole history. RefreshRollups must have run.
+func (c *Cache) ApplyChanges(entries map[string]*usage.Entry, changes *ChangeSet, stats *usage.MergeStats) map[string]*usage.Entry {
+	if changes.all {
+		return c.Dedup(stats)
+	}
+	for key := range changes.keys {
+		delete(entries, key)
+	}
+	for key, e := range c.winners(changes.keys, stats) {
+		entries[key] = e
+	}
+	return entries
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// splitUsage separates main-thread from subagent (sidechain) traffic.
+type splitUsage struct {
+	MainTokens     int     `json:"main_tokens"`
+	MainCost       float64 `json:"main_cost_usd"`
+	SubagentTokens int     `json:"subagent_tokens"`
+	SubagentCost   float64 `json:"subagent_cost_usd"`
+}
+
+func (s *splitUsage) add(e *usage.Entry, cost float64) {
+	if e.Sidechain {
+		s.SubagentTokens += e.TotalTokens()
+		s.SubagentCost += cost
+	} else {
+		s.MainTokens += e.TotalTokens()
+		s.MainCost += cost
+	}
+}
+
+// subagentShare returns the subagent share of cost as a percentage.
+func (s *splitUsage) subagentShare() float64 {
+	if total := s.MainCost + s.SubagentCost; total > 0 {
+		return s.SubagentCost / total * 100
+	}
+	return 0
+}
+
+type subagentDay struct {
+	Date string `json:"date"`
+	splitUsage
+}
+
+type subagentSession struct {
+	Session   string `json:"session"`
+	Project   string `json:"project"`
+	Subagents int    `json:"subagents"`
+	splitUsage
+}
+
+// subagentInvocation is one subagent run: the requests of one agent transcript,
+// or the sidechain requests of a session when logs don't identify the agent.
+type subagentInvocation struct {
+	Agent    string    `json:"agent"`
+	Session  string    `json:"session"`
+	Project  string    `json:"project"`
+	Start    time.Time `json:"start"`
+	Requests int       `json:"requests"`
+	Tokens   int       `json:"tokens"`
+	CostUSD  float64   `json:"cost_usd"`
+}
+
+type subagentReport struct {
+	Range       string               `json:"range"`
+	Total       splitUsage           `json:"total"`
+	Days        []subagentDay        `json:"days"`
+	Sessions    []subagentSession    `json:"sessions"`
+	Invocations []subagentInvocation `json:"invocations"`
+}
+
+// buildSubagentReport splits entries by day and session, and ranks subagent
+// invocations and sessions by subagent cost, keeping the top of each.
+func buildSubagentReport(entries map[string]*usage.Entry, top int, mode usage.CostMode) *subagentReport {
+	r := &subagentReport{Days: []subagentDay{}, Sessions: []subagentSession{}, Invocations: []subagentInvocation{}}
+	days := make(map[string]*subagentDay)
+	sessions := make(map[string]*subagentSession)
+	type invocationKey struct{ session, agent string }
+	invocations := make(map[invocationKey]*subagentInvocation)
+	for _, e := range entries {
+		cost := mode.EntryCost(e, pricing.Default)
+		r.Total.add(e, cost)
+		if days[e.Date] == nil {
+			days[e.Date] = &subagentDay{Date: e.Date}
+		}
+		days[e.Date].add(e, cost)
+		if sessions[e.Session] == nil {
+			sessions[e.Session] = &subagentSession{Session: e.Session, Project: e.Project}
+		}
+		sessions[e.Session].add(e, cost)
+		if !e.Sidechain {
+			continue
+		}
+
+		k := invocationKey{e.Session, e.Agent}
+		inv := invocations[k]
+		if inv == nil {
+			inv = &subagentInvocation{Agent: e.Agent, Session: e.Session, Project: e.Project}
+			if inv.Agent == "" {
+				inv.Agent = "(sidechain)"
+			}
+			invocations[k] = inv
+			sessions[e.Session].Subagents++
+		}
+		t := time.Unix(e.Timestamp, 0).UTC()
+		if inv.Start.IsZero() || t.Before(inv.Start) {
+			inv.Start = t
+		}
+		inv.Requests++
+		inv.Tokens += e.TotalTokens()
+		inv.CostUSD += cost
+	}
+
+	for _, d := range days {
+		r.Days = append(r.Days, *d)
+	}
+	sort.Slice(r.Days, func(i, j int) bool { return r.Days[i].Date < r.Days[j].Date })
+	for _, s := range sessions {
+		if s.SubagentTokens > 0 {
+			r.Sessions = append(r.Sessions, *s)
+		}
+	}
+	sort.Slice(r.Sessions, func(i, j int) bool {
+		if r.Sessions[i].SubagentCost != r.Sessions[j].SubagentCost {
+			return r.Sessions[i].SubagentCost > r.Sessions[j].SubagentCost
+		}
+		return r.Sessions[i].Session < r.Sessions[j].Session
+	})
+	for _, inv := range invocations {
+		r.Invocations = append(r.Invocations, *inv)
+	}
+	sort.Slice(r.Invocations, func(i, j int) bool {
+		if r.Invocations[i].CostUSD != r.Invocations[j].CostUSD {
+			return r.Invocations[i].CostUSD > r.Invocations[j].CostUSD
+		}
+		return r.Invocations[i].Start.Before(r.Invocations[j].Start)
+	})
+	if top > 0 {
+		r.Sessions = r.Sessions[:min(top, len(r.Sessions))]
+		r.Invocations = r.Invocations[:min(top, len(r.Invocations))]
+	}
+	return r
+}
+
+func runSubagents(args []string) {
+	fs := flag.NewFlagSet("subagents", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	top := fs.Int("top", 10, "number of sessions and subagent invocations to list (0 for all)")
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	opts := common.options(fs)
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+	if *top < 0 {
+		fmt.Fprintf(os.Stderr, "error: --top must not be negative\n")
+		os.Exit(1)
+	}
+
+	entries := common.loadEntries(opts)
+	r := buildSubagentReport(entries, *top, opts.costMode)
+	r.Range = opts.describe()
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return
+	}
+	printSubagentReport(r)
+}
+
+func printSubagentReport(r *subagentReport) {
+	fmt.Printf("Main thread vs subagents, %s\n\n", r.Range)
+	rowFormat := "%-15s %17s %12s %17s %12s %10s\n"
+	width := 15 + 73
+	fmt.Printf(rowFormat, "Date", "MainTokens", "MainCost", "SubagentTokens", "SubagentCost", "Subagent%")
+	fmt.Println(strings.Repeat("-", width))
+	for _, d := range r.Days {
+		fmt.Printf(rowFormat, d.Date, render.FormatNumber(d.MainTokens), render.FormatDollars(d.MainCost),
+			render.FormatNumber(d.SubagentTokens), render.FormatDollars(d.SubagentCost), fmt.Sprintf("%.1f%%", d.subagentShare()))
+	}
+	fmt.Println(strings.Repeat("-", width))
+	fmt.Printf(rowFormat, "Total", render.FormatNumber(r.Total.MainTokens), render.FormatDollars(r.Total.MainCost),
+		render.FormatNumber(r.Total.SubagentTokens), render.FormatDollars(r.Total.SubagentCost), fmt.Sprintf("%.1f%%", r.Total.subagentShare()))
+
+	if len(r.Sessions) == 0 {
+		return
+	}
+	sessionWidth := 7
+	for _, s := range r.Sessions {
+		sessionWidth = max(sessionWidth, len(s.Session))
+	}
+	fmt.Printf("\nSessions by subagent cost\n\n")
+	rowFormat = fmt.Sprintf("%%-%ds %%12s %%12s %%10s %%9s  %%s\n", sessionWidth)
+	fmt.Printf(rowFormat, "Session", "MainCost", "SubagentCost", "Subagent%", "Subagents", "Project")
+	fmt.Println(strings.Repeat("-", sessionWidth+56))
+	for _, s := range r.Sessions {
+		fmt.Printf(rowFormat, s.Session, render.FormatDollars(s.MainCost), render.FormatDollars(s.SubagentCost),
+			fmt.Sprintf("%.1f%%", s.subagentShare()), render.FormatNumber(s.Subagents), s.Project)
+	}
+
+	agentWidth := 5
+	for _, inv := range r.Invocations {
+		agentWidth = max(agentWidth, len(inv.Agent))
+	}
+	fmt.Printf("\nMost expensive subagent invocations\n\n")
+	rowFormat = fmt.Sprintf("%%-%ds %%-16s %%8s %%17s %%12s  %%s\n", agentWidth)
+	fmt.Printf(rowFormat, "Agent", "Started (UTC)", "Requests", "Tokens", "Cost", "Session")
+	fmt.Println(strings.Repeat("-", agentWidth+60+sessionWidth))
+	for _, inv := range r.Invocations {
+		fmt.Printf(rowFormat, inv.Agent, inv.Start.Format("2006-01-02 15:04"), render.FormatNumber(inv.Requests),
+			render.FormatNumber(inv.Tokens), render.FormatDollars(inv.CostUSD), inv.Session)
+	}
+}
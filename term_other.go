@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("interactive terminal is not supported on this platform")
+
+func makeRaw(fd int) (func(), error) { return nil, errNoTerminal }
+
+func terminalSize(fd int) (width, height int, err error) { return 0, 0, errNoTerminal }
+
+func notifyResize(ch chan<- os.Signal) {}
+
+func notifyStop(ch chan<- os.Signal) {}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// noToolLabel collects requests that made no tool calls, so attributed cost
+// adds up to the total.
+const noToolLabel = "(no tools)"
+
+// toolUsage counts the calls of one tool, optionally within one day or project.
+type toolUsage struct {
+	Tool     string `json:"tool"`
+	Group    string `json:"group,omitempty"` // date or project when grouped
+	Calls    int    `json:"calls"`
+	Requests int    `json:"requests"`
+	// AttributedCost splits each request's cost evenly across its tool calls;
+	// RequestCost is the full cost of every request that called the tool.
+	AttributedCost float64 `json:"attributed_cost_usd"`
+	RequestCost    float64 `json:"request_cost_usd"`
+}
+
+type toolReport struct {
+	Range   string      `json:"range"`
+	GroupBy string      `json:"group_by"`
+	Total   float64     `json:"total_cost_usd"`
+	Tools   []toolUsage `json:"tools"`
+}
+
+// toolGroup names the row a tool is counted under: MCP tools
+// (mcp__<server>__<tool>) are grouped by server.
+func toolGroup(name string) string {
+	if rest, ok := strings.CutPrefix(name, "mcp__"); ok {
+		server, _, _ := strings.Cut(rest, "__")
+		return "mcp:" + server
+	}
+	return name
+}
+
+func buildToolReport(entries map[string]*usage.Entry, groupBy string, mode usage.CostMode) *toolReport {
+	type rowKey struct{ group, tool string }
+	rows := make(map[rowKey]*toolUsage)
+	row := func(group, tool string) *toolUsage {
+		k := rowKey{group, tool}
+		if rows[k] == nil {
+			rows[k] = &toolUsage{Tool: tool, Group: group}
+		}
+		return rows[k]
+	}
+
+	r := &toolReport{GroupBy: groupBy, Tools: []toolUsage{}}
+	for _, e := range entries {
+		group := ""
+		switch groupBy {
+		case "date":
+			group = e.Date
+		case "project":
+			group = e.Project
+		}
+		cost := mode.EntryCost(e, pricing.Default)
+		r.Total += cost
+		if len(e.Tools) == 0 {
+			u := row(group, noToolLabel)
+			u.Requests++
+			u.AttributedCost += cost
+			u.RequestCost += cost
+			continue
+		}
+		share := cost / float64(len(e.Tools))
+		counted := make(map[string]bool)
+		for _, tool := range e.Tools {
+			u := row(group, toolGroup(tool))
+			u.Calls++
+			u.AttributedCost += share
+			if !counted[u.Tool] {
+				counted[u.Tool] = true
+				u.Requests++
+				u.RequestCost += cost
+			}
+		}
+	}
+
+	for _, u := range rows {
+		r.Tools = append(r.Tools, *u)
+	}
+	sort.Slice(r.Tools, func(i, j int) bool {
+		a, b := r.Tools[i], r.Tools[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.AttributedCost != b.AttributedCost {
+			return a.AttributedCost > b.AttributedCost
+		}
+		return a.Tool < b.Tool
+	})
+	return r
+}
+
+func runTools(args []string) {
+	fs := flag.NewFlagSet("tools", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	groupBy := fs.String("group-by", "tool", "break tools down by: tool, date, project")
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	opts := common.options(fs)
+	if *groupBy != "tool" && *groupBy != "date" && *groupBy != "project" {
+		fmt.Fprintf(os.Stderr, "error: --group-by must be one of: tool, date, project\n")
+		os.Exit(1)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+
+	entries := common.loadEntries(opts)
+	r := buildToolReport(entries, *groupBy, opts.costMode)
+	r.Range = opts.describe()
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return
+	}
+	printToolReport(r)
+}
+
+func printToolReport(r *toolReport) {
+	toolWidth, groupWidth := 15, 0
+	groupLabel := map[string]string{"date": "Date", "project": "Project"}[r.GroupBy]
+	if groupLabel != "" {
+		groupWidth = len(groupLabel) + 1
+	}
+	for _, u := range r.Tools {
+		toolWidth = max(toolWidth, min(len(u.Tool), 40))
+		if groupLabel != "" {
+			groupWidth = max(groupWidth, len(u.Group)+1)
+		}
+	}
+
+	fmt.Printf("Tool calls and attributed cost, %s\n\n", r.Range)
+	rowFormat := fmt.Sprintf("%%-%ds%%-%ds %%9s %%9s %%12s %%7s %%12s\n", groupWidth, toolWidth)
+	width := groupWidth + toolWidth + 56
+	fmt.Printf(rowFormat, groupLabel, "Tool", "Calls", "Requests", "Cost", "Share", "Cost/Req")
+	fmt.Println(strings.Repeat("-", width))
+	prevGroup := ""
+	for i, u := range r.Tools {
+		group := u.Group
+		if i > 0 && group == prevGroup {
+			group = ""
+		}
+		prevGroup = u.Group
+		share := 0.0
+		if r.Total > 0 {
+			share = u.AttributedCost / r.Total * 100
+		}
+		fmt.Printf(rowFormat, group, u.Tool, render.FormatNumber(u.Calls), render.FormatNumber(u.Requests),
+			render.FormatDollars(u.AttributedCost), fmt.Sprintf("%.1f%%", share), render.FormatDollars(u.RequestCost/float64(max(u.Requests, 1))))
+	}
+	fmt.Println(strings.Repeat("-", width))
+	fmt.Printf(rowFormat, "", "Total", "", "", render.FormatDollars(r.Total), "", "")
+	fmt.Printf("\nCost splits each request evenly across its tool calls; Cost/Req is the average full cost of requests calling the tool.\n")
+}
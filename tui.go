@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/store"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// tuiRange selects the period shown by the dashboard.
+type tuiRange int
+
+const (
+	tuiDay tuiRange = iota
+	tuiWeek
+	tuiMonth
+)
+
+func (r tuiRange) String() string {
+	switch r {
+	case tuiDay:
+		return "Today"
+	case tuiWeek:
+		return "Last 7 days"
+	default:
+		return "Month to date"
+	}
+}
+
+func (r tuiRange) options() reportOptions {
+	switch r {
+	case tuiDay:
+		return reportOptions{days: 1, daysExplicit: true}
+	case tuiWeek:
+		return reportOptions{days: 7, daysExplicit: true}
+	default:
+		return reportOptions{}
+	}
+}
+
+// tuiState is the dashboard's view state, changed by keyboard input.
+type tuiState struct {
+	rng       tuiRange
+	byProject bool
+	scroll    int
+	width     int
+	height    int
+	costMode  usage.CostMode
+}
+
+// handleKey applies a chunk of keyboard input to the state and reports whether
+// the user asked to quit.
+func (s *tuiState) handleKey(in []byte) bool {
+	switch string(in) {
+	case "q", "Q", "\x03":
+		return true
+	case "d":
+		s.rng, s.scroll = tuiDay, 0
+	case "w":
+		s.rng, s.scroll = tuiWeek, 0
+	case "m":
+		s.rng, s.scroll = tuiMonth, 0
+	case "\x1b[C", "l":
+		s.rng, s.scroll = (s.rng+1)%3, 0
+	case "\x1b[D", "h":
+		s.rng, s.scroll = (s.rng+2)%3, 0
+	case "p", "\t":
+		s.byProject = !s.byProject
+	case "j", "\x1b[B":
+		s.scroll++
+	case "k", "\x1b[A":
+		s.scroll--
+	case "\x1b[6~", " ":
+		s.scroll += 10
+	case "\x1b[5~":
+		s.scroll -= 10
+	case "g":
+		s.scroll = 0
+	}
+	return false
+}
+
+func runTUI(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	noCache := fs.Bool("no-cache", false, "skip reading cache (still writes cache)")
+	dedup := addDedupFlag(fs)
+	costModeName := addCostModeFlag(fs)
+	srcFlags := addSourceFlags(fs)
+	_ = fs.Parse(args)
+	strategy := dedupStrategy(*dedup)
+	mode := costMode(*costModeName)
+
+	fd := int(os.Stdin.Fd())
+	width, height, err := terminalSize(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: tui requires a terminal: %v\n", err)
+		os.Exit(1)
+	}
+
+	cache, cacheValid := store.Open(*noCache, false)
+	cache.SetDedup(strategy)
+	sources := srcFlags.sources()
+	dirty := refreshCache(cache, sources, cacheValid)
+	var dedupStats usage.MergeStats
+	entries := cache.Dedup(&dedupStats)
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: tui: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restore()
+		if dirty {
+			_ = cache.Save()
+		}
+	}()
+
+	// Without a watcher the dashboard still refreshes on the save ticker
+	var events <-chan struct{}
+	w, err := newDirWatcher()
+	if err == nil {
+		w.sync(cache.Dirs)
+		events = w.events
+	}
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	// Raw mode turns off ISIG, so only signals from outside reach us; restore
+	// the terminal on the way out rather than dying in the alternate screen
+	stop := make(chan os.Signal, 1)
+	notifyStop(stop)
+	ticker := time.NewTicker(watchSaveInterval)
+	defer ticker.Stop()
+
+	state := &tuiState{width: width, height: height, costMode: mode}
+	refresh := func() {
+		// The background context is never cancelled, so this cannot fail
+		changes, changed, _ := cache.RefreshChanges(context.Background(), sources, true)
+		if changed {
+			dirty = true
+			entries = cache.ApplyChanges(entries, changes, &dedupStats)
+		}
+		if w != nil {
+			w.sync(cache.Dirs)
+		}
+	}
+	draw := func() {
+		_, _ = os.Stdout.WriteString(renderTUI(cache, entries, state, time.Now()))
+	}
+	draw()
+
+	for {
+		select {
+		case in, ok := <-keys:
+			if !ok || state.handleKey(in) {
+				return
+			}
+		case <-events:
+			drainEvents(events)
+			refresh()
+		case <-stop:
+			return
+		case <-resize:
+			if width, height, err := terminalSize(fd); err == nil {
+				state.width, state.height = width, height
+			}
+		case <-ticker.C:
+			refresh()
+			if dirty {
+				_ = cache.Save()
+				dirty = false
+			}
+		}
+		draw()
+	}
+}
+
+// renderTUI draws a full frame of the dashboard as a string of terminal output.
+func renderTUI(cache *store.Cache, entries map[string]*usage.Entry, state *tuiState, now time.Time) string {
+	width, height := max(state.width, 40), max(state.height, 10)
+	now = now.UTC()
+	today := now.Format("2006-01-02")
+	var lines []string
+
+	view := "models"
+	if state.byProject {
+		view = "projects"
+	}
+	lines = append(lines, "\x1b[7m"+fitWidth(fmt.Sprintf(" ccusage  %s  by %s", state.rng, view), width)+"\x1b[0m")
+	lines = append(lines, "")
+
+	// Top panes: today's spend and the active billing window
+	var todayCost float64
+	if r := cache.Rollups[today]; r != nil {
+		todayCost = state.costMode.Cost(&usage.DayUsage{Models: r.Models}, pricing.Default)
+	}
+	var todayRequests, todayTokens int
+	for _, e := range entries {
+		if e.Date == today {
+			todayRequests++
+			todayTokens += e.TotalTokens()
+		}
+	}
+	left := []string{
+		"\x1b[1mToday\x1b[0m",
+		render.FormatDollars(todayCost),
+		fmt.Sprintf("%s requests, %s tokens", render.FormatNumber(todayRequests), render.FormatNumber(todayTokens)),
+	}
+	right := []string{"\x1b[1mActive billing window\x1b[0m", "none", ""}
+	if b := currentBlock(cache, now); b != nil {
+		remaining := b.End.Sub(now).Truncate(time.Minute)
+		right[1] = fmt.Sprintf("%s  (%s–%s UTC)", render.FormatDollars(blockCost(b, pricing.Default, state.costMode)),
+			b.Start.Format("15:04"), b.End.Format("15:04"))
+		right[2] = fmt.Sprintf("%s left, %s requests", formatRemaining(remaining), render.FormatNumber(len(b.Entries)))
+	}
+	half := width / 2
+	for i := range left {
+		lines = append(lines, " "+padWidth(left[i], half-2)+"│ "+right[i])
+	}
+	lines = append(lines, "")
+
+	// Bar chart of cost per model or project over the selected range
+	rangeEntries := usage.Since(entries, state.rng.options().cutoff())
+	keyFn := func(e *usage.Entry) string { return e.Model }
+	if state.byProject {
+		keyFn = func(e *usage.Entry) string { return e.Project }
+	}
+	type bar struct {
+		label string
+		cost  float64
+	}
+	var bars []bar
+	var rangeTotal, maxCost float64
+	for label, group := range usage.AggregateBy(rangeEntries, keyFn) {
+		cost := state.costMode.Cost(group, pricing.Default)
+		bars = append(bars, bar{label, cost})
+		rangeTotal += cost
+		maxCost = max(maxCost, cost)
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		if bars[i].cost != bars[j].cost {
+			return bars[i].cost > bars[j].cost
+		}
+		return bars[i].label < bars[j].label
+	})
+	lines = append(lines, fmt.Sprintf(" \x1b[1mCost by %s\x1b[0m  %s total", strings.TrimSuffix(view, "s"), render.FormatDollars(rangeTotal)))
+	maxBars := max((height-12)/2, 3)
+	labelWidth := 12
+	for i, b := range bars {
+		if i == maxBars {
+			break
+		}
+		labelWidth = max(labelWidth, min(utf8.RuneCountInString(b.label), 32))
+	}
+	barWidth := max(width-labelWidth-16, 10)
+	for i, b := range bars {
+		if i == maxBars {
+			lines = append(lines, fmt.Sprintf(" … %d more", len(bars)-maxBars))
+			break
+		}
+		lines = append(lines, fmt.Sprintf(" %s %12s %s", padWidth(b.label, labelWidth), render.FormatDollars(b.cost),
+			renderBar(b.cost, maxCost, barWidth)))
+	}
+	if len(bars) == 0 {
+		lines = append(lines, " no usage in range")
+	}
+	lines = append(lines, "")
+
+	// Scrolling list of the most expensive requests in range
+	type request struct {
+		e    *usage.Entry
+		cost float64
+	}
+	requests := make([]request, 0, len(rangeEntries))
+	for _, e := range rangeEntries {
+		requests = append(requests, request{e, state.costMode.EntryCost(e, pricing.Default)})
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].cost != requests[j].cost {
+			return requests[i].cost > requests[j].cost
+		}
+		return requests[i].e.Timestamp > requests[j].e.Timestamp
+	})
+	visible := max(height-len(lines)-3, 1)
+	state.scroll = max(min(state.scroll, len(requests)-visible), 0)
+	lines = append(lines, fmt.Sprintf(" \x1b[1mMost expensive requests\x1b[0m  %d–%d of %d",
+		min(state.scroll+1, len(requests)), min(state.scroll+visible, len(requests)), len(requests)))
+	lines = append(lines, fmt.Sprintf(" %-11s  %-28s  %-28s %13s %10s", "Time", "Model", "Project", "Tokens", "Cost"))
+	for _, r := range requests[state.scroll:min(state.scroll+visible, len(requests))] {
+		lines = append(lines, fmt.Sprintf(" %-11s  %s  %s %13s %10s",
+			time.Unix(r.e.Timestamp, 0).UTC().Format("01-02 15:04"),
+			padWidth(r.e.Model, 28), padWidth(r.e.Project, 28),
+			render.FormatNumber(r.e.TotalTokens()), render.FormatDollars(r.cost)))
+	}
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = lines[:height-1]
+	footer := fmt.Sprintf(" d/w/m range  ←/→ cycle  p %s  j/k scroll  q quit", map[bool]string{true: "models", false: "projects"}[state.byProject])
+	updated := "updated " + now.Format("15:04:05") + " "
+	lines = append(lines, "\x1b[7m"+padWidth(footer, width-utf8.RuneCountInString(updated))+updated+"\x1b[0m")
+
+	return "\x1b[H" + strings.Join(lines, "\x1b[K\r\n") + "\x1b[K\x1b[J"
+}
+
+// renderBar draws a horizontal bar of width cells scaled so that maxValue fills it,
+// using eighth-block characters for the fractional cell.
+func renderBar(value, maxValue float64, width int) string {
+	if maxValue <= 0 || value <= 0 {
+		return ""
+	}
+	eighths := int(value / maxValue * float64(width*8))
+	bar := strings.Repeat("█", eighths/8)
+	if rem := eighths % 8; rem > 0 {
+		bar += string([]rune("▏▎▍▌▋▊▉")[rem-1])
+	}
+	return bar
+}
+
+// formatRemaining formats a duration as e.g. "2h13m".
+func formatRemaining(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// fitWidth truncates s to at most width runes, marking truncation with an ellipsis.
+func fitWidth(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string([]rune(s)[:max(width, 0)])
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+// padWidth truncates or pads s with spaces to exactly width visible runes. ANSI
+// SGR sequences in s do not count towards the width.
+func padWidth(s string, width int) string {
+	var visible int
+	if strings.Contains(s, "\x1b[") {
+		visible = utf8.RuneCountInString(stripANSI(s))
+	} else {
+		s = fitWidth(s, width)
+		visible = utf8.RuneCountInString(s)
+	}
+	if visible < width {
+		s += strings.Repeat(" ", width-visible)
+	}
+	return s
+}
+
+// stripANSI removes SGR escape sequences such as "\x1b[1m".
+func stripANSI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '[' {
+			j := i + 2
+			for j < len(s) && s[j] != 'm' {
+				j++
+			}
+			i = j
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
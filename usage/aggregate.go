@@ -0,0 +1,111 @@
+package usage
+
+// Aggregate sums per-model usage per day.
+func Aggregate(entries map[string]*Entry) map[string]*DayUsage {
+	return AggregateBy(entries, func(e *Entry) string { return e.Date })
+}
+
+// AggregateBy sums per-model usage into groups named by keyFn.
+func AggregateBy(entries map[string]*Entry, keyFn func(*Entry) string) map[string]*DayUsage {
+	groups := make(map[string]*DayUsage)
+	for _, e := range entries {
+		key := keyFn(e)
+		if groups[key] == nil {
+			groups[key] = &DayUsage{Models: make(map[string]*Usage)}
+		}
+		if groups[key].Models[e.Model] == nil {
+			groups[key].Models[e.Model] = &Usage{}
+		}
+		groups[key].Models[e.Model].Add(e)
+	}
+	return groups
+}
+
+// Since returns the entries dated on or after cutoff.
+func Since(entries map[string]*Entry, cutoff string) map[string]*Entry {
+	filtered := make(map[string]*Entry, len(entries))
+	for key, e := range entries {
+		if e.Date >= cutoff {
+			filtered[key] = e
+		}
+	}
+	return filtered
+}
+
+// Rollup aggregates deduplicated entries into per-day rollups.
+func Rollup(entries map[string]*Entry) map[string]*DayRollup {
+	rollups := make(map[string]*DayRollup)
+	for date, day := range Aggregate(entries) {
+		rollups[date] = &DayRollup{Models: day.Models, Projects: make(map[string]map[string]*Usage)}
+	}
+	for _, e := range entries {
+		projects := rollups[e.Date].Projects
+		if projects[e.Project] == nil {
+			projects[e.Project] = make(map[string]*Usage)
+		}
+		if projects[e.Project][e.Model] == nil {
+			projects[e.Project][e.Model] = &Usage{}
+		}
+		projects[e.Project][e.Model].Add(e)
+	}
+	return rollups
+}
+
+// SumRollups totals the rollups for dates in [since, until] per model and per
+// project. An empty since or until leaves that end of the range open.
+func SumRollups(rollups map[string]*DayRollup, since, until string) (models map[string]*Usage, projects map[string]map[string]*Usage) {
+	models = make(map[string]*Usage)
+	projects = make(map[string]map[string]*Usage)
+	for date, r := range rollups {
+		if !InRange(date, since, until) {
+			continue
+		}
+		for model, u := range r.Models {
+			if models[model] == nil {
+				models[model] = &Usage{}
+			}
+			models[model].Merge(u)
+		}
+		for project, projectModels := range r.Projects {
+			if projects[project] == nil {
+				projects[project] = make(map[string]*Usage)
+			}
+			for model, u := range projectModels {
+				if projects[project][model] == nil {
+					projects[project][model] = &Usage{}
+				}
+				projects[project][model].Merge(u)
+			}
+		}
+	}
+	return models, projects
+}
+
+// InRange reports whether date is in [since, until]; an empty until is open.
+func InRange(date, since, until string) bool {
+	return date >= since && (until == "" || date <= until)
+}
+
+// RollupDays returns the rollups as DayUsage for dates on or after cutoff.
+// An empty cutoff returns every day.
+func RollupDays(rollups map[string]*DayRollup, cutoff string) map[string]*DayUsage {
+	dayUsage := make(map[string]*DayUsage, len(rollups))
+	for date, r := range rollups {
+		if date >= cutoff {
+			dayUsage[date] = &DayUsage{Models: r.Models}
+		}
+	}
+	return dayUsage
+}
+
+// SumTokens totals each token category across models, counting both cache
+// write durations as cache writes.
+func SumTokens(day *DayUsage) (input, output, cacheWrite, cacheRead int) {
+	for _, u := range day.Models {
+		input += u.Input
+		output += u.Output
+		cacheWrite += u.CacheWrite + u.CacheWrite1h
+		cacheRead += u.CacheRead
+	}
+	return
+}
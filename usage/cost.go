@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abatilo/ccusage-go/pricing"
+)
+
+// addCost adds the cost of u at p to total.
+func addCost(total *float64, u *Usage, p pricing.Rates) {
+	*total += (float64(u.Input)*p.Input +
+		float64(u.Output)*p.Output +
+		float64(u.CacheWrite)*p.CacheWrite +
+		float64(u.CacheWrite1h)*p.CacheWrite1h +
+		float64(u.CacheRead)*p.CacheRead) / 1_000_000
+	*total += float64(u.WebSearchRequests) * pricing.WebSearchCost
+}
+
+// Cost returns the cost of day at the model's own speed.
+func Cost(day *DayUsage, prices pricing.Table) float64 {
+	var total float64
+	for model, u := range day.Models {
+		addCost(&total, u, prices.Lookup(model))
+	}
+	return total
+}
+
+// EntryCost returns the cost of a single request.
+func EntryCost(e *Entry, prices pricing.Table) float64 {
+	u := &Usage{}
+	u.addTokens(e)
+	var total float64
+	addCost(&total, u, prices.Lookup(e.Model))
+	return total
+}
+
+// CostMode decides whether a request is costed from its tokens or from the
+// cost the client logged for it.
+type CostMode string
+
+const (
+	// CostAuto uses the logged cost when a request has one and prices its
+	// tokens otherwise.
+	CostAuto CostMode = "auto"
+	// CostCalculate always prices tokens.
+	CostCalculate CostMode = "calculate"
+	// CostDisplay prices tokens like CostCalculate; reports show the logged
+	// cost and the discrepancy alongside.
+	CostDisplay CostMode = "display"
+)
+
+// ParseCostMode returns the cost mode named s.
+func ParseCostMode(s string) (CostMode, error) {
+	switch m := CostMode(s); m {
+	case CostAuto, CostCalculate, CostDisplay:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown cost mode %q, want one of: auto, calculate, display", s)
+}
+
+// Cost returns the cost of day in mode m. The empty mode is CostAuto.
+func (m CostMode) Cost(day *DayUsage, prices pricing.Table) float64 {
+	total := Cost(day, prices)
+	if m == CostCalculate || m == CostDisplay {
+		return total
+	}
+	for model, u := range day.Models {
+		if u.Logged != nil {
+			var calculated float64
+			addCost(&calculated, u.Logged, prices.Lookup(model))
+			total += u.LoggedCost - calculated
+		}
+	}
+	return total
+}
+
+// EntryCost returns the cost of a single request in mode m.
+func (m CostMode) EntryCost(e *Entry, prices pricing.Table) float64 {
+	if e.HasLoggedCost && m != CostCalculate && m != CostDisplay {
+		return e.LoggedCostUSD
+	}
+	return EntryCost(e, prices)
+}
+
+// LoggedCost returns the cost logged for the requests of day that carry one,
+// and what the same requests cost by their tokens.
+func LoggedCost(day *DayUsage, prices pricing.Table) (logged, calculated float64) {
+	for model, u := range day.Models {
+		if u.Logged != nil {
+			logged += u.LoggedCost
+			addCost(&calculated, u.Logged, prices.Lookup(model))
+		}
+	}
+	return logged, calculated
+}
+
+// CostAllRegular returns the cost of day as if every request ran at regular speed.
+func CostAllRegular(day *DayUsage, prices pricing.Table) float64 {
+	var total float64
+	for model, u := range day.Models {
+		addCost(&total, u, prices.Lookup(strings.TrimSuffix(model, ":fast")))
+	}
+	return total
+}
+
+// CostAllFast returns the cost of day as if every request ran in fast mode,
+// for models that offer it.
+func CostAllFast(day *DayUsage, prices pricing.Table) float64 {
+	var total float64
+	for model, u := range day.Models {
+		if !strings.HasSuffix(model, ":fast") {
+			if _, ok := prices[model+":fast"]; ok {
+				model += ":fast"
+			}
+		}
+		addCost(&total, u, prices.Lookup(model))
+	}
+	return total
+}
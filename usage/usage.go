@@ -0,0 +1,192 @@
+// Package usage defines the parsed request record and aggregates requests into
+// per-model token totals and costs.
+package usage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Entry is one deduplicated API request parsed from a log.
+type Entry struct {
+	Key                 string   `json:"key"`
+	Source              string   `json:"source"` // tool that logged the request, e.g. "claude-code"
+	Date                string   `json:"date"`
+	Timestamp           int64    `json:"timestamp"` // Unix seconds
+	Model               string   `json:"model"`
+	Project             string   `json:"project"`
+	Root                string   `json:"root"`
+	Branch              string   `json:"branch"`
+	Cwd                 string   `json:"cwd"`
+	Session             string   `json:"session"`
+	Agent               string   `json:"agent"`           // subagent invocation, "" for the main thread
+	Sidechain           bool     `json:"sidechain"`       // subagent (Task) traffic
+	Version             string   `json:"version"`         // Claude Code version that logged the request
+	Tools               []string `json:"tools,omitempty"` // tool_use blocks, one name per call
+	InputTokens         int      `json:"input_tokens"`
+	OutputTokens        int      `json:"output_tokens"`
+	CacheCreationTokens int      `json:"cache_creation_tokens"`
+	CacheWrite1hTokens  int      `json:"cache_write_1h_tokens"`
+	CacheReadTokens     int      `json:"cache_read_tokens"`
+	WebSearchRequests   int      `json:"web_search_requests"`
+	// LoggedCostUSD is the cost the client logged for the request, which older
+	// Claude Code versions wrote as costUSD; HasLoggedCost reports whether it did.
+	LoggedCostUSD float64 `json:"logged_cost_usd,omitempty"`
+	HasLoggedCost bool    `json:"has_logged_cost,omitempty"`
+}
+
+// TotalTokens returns every input, output and cache token of the request.
+func (e *Entry) TotalTokens() int {
+	return e.InputTokens + e.OutputTokens + e.CacheCreationTokens + e.CacheWrite1hTokens + e.CacheReadTokens
+}
+
+// SameUsage reports whether e and o carry identical token and web search
+// counts, as repeated copies of one response do.
+func (e *Entry) SameUsage(o *Entry) bool {
+	return e.InputTokens == o.InputTokens && e.OutputTokens == o.OutputTokens &&
+		e.CacheCreationTokens == o.CacheCreationTokens && e.CacheWrite1hTokens == o.CacheWrite1hTokens &&
+		e.CacheReadTokens == o.CacheReadTokens && e.WebSearchRequests == o.WebSearchRequests
+}
+
+// Usage is the token and request totals for one model.
+type Usage struct {
+	Input             int
+	Output            int
+	CacheWrite        int
+	CacheWrite1h      int
+	CacheRead         int
+	WebSearchRequests int
+	// LoggedCost is the cost logged by the client for the requests that carry
+	// one, and Logged their usage, so either cost can be reported.
+	LoggedCost float64
+	Logged     *Usage
+}
+
+// Add adds the tokens of e.
+func (u *Usage) Add(e *Entry) {
+	u.addTokens(e)
+	if e.HasLoggedCost {
+		u.LoggedCost += e.LoggedCostUSD
+		if u.Logged == nil {
+			u.Logged = &Usage{}
+		}
+		u.Logged.addTokens(e)
+	}
+}
+
+func (u *Usage) addTokens(e *Entry) {
+	u.Input += e.InputTokens
+	u.Output += e.OutputTokens
+	u.CacheWrite += e.CacheCreationTokens
+	u.CacheWrite1h += e.CacheWrite1hTokens
+	u.CacheRead += e.CacheReadTokens
+	u.WebSearchRequests += e.WebSearchRequests
+}
+
+// Merge adds src into u.
+func (u *Usage) Merge(src *Usage) {
+	u.Input += src.Input
+	u.Output += src.Output
+	u.CacheWrite += src.CacheWrite
+	u.CacheWrite1h += src.CacheWrite1h
+	u.CacheRead += src.CacheRead
+	u.WebSearchRequests += src.WebSearchRequests
+	u.LoggedCost += src.LoggedCost
+	if src.Logged != nil {
+		if u.Logged == nil {
+			u.Logged = &Usage{}
+		}
+		u.Logged.Merge(src.Logged)
+	}
+}
+
+// DayUsage is per-model usage for one group of entries, usually a day.
+type DayUsage struct {
+	Models map[string]*Usage
+}
+
+// DayRollup is the pre-aggregated usage for a single day. Rollups are persisted in
+// the cache so warm runs only re-aggregate days touched by changed files.
+type DayRollup struct {
+	Models   map[string]*Usage
+	Projects map[string]map[string]*Usage // project -> model -> usage
+}
+
+// MergeStats counts the outcome of deduplicating entries.
+type MergeStats struct {
+	Unique    int
+	Conflicts int
+}
+
+// MergeEntry adds e to the dedup map, keeping whichever copy of a key has more tokens.
+func MergeEntry(all map[string]*Entry, e *Entry, stats *MergeStats) {
+	DedupMaxTokens.Merge(all, e, stats)
+}
+
+// Dedup is a strategy for picking the copy of a request that is counted when
+// it was logged more than once, e.g. in several files after a session was
+// resumed or forked.
+type Dedup string
+
+const (
+	// DedupMaxTokens keeps the copy with the most tokens.
+	DedupMaxTokens Dedup = "max-tokens"
+	// DedupFirstSeen keeps the earliest copy.
+	DedupFirstSeen Dedup = "first-seen"
+	// DedupLastSeen keeps the latest copy.
+	DedupLastSeen Dedup = "last-seen"
+	// DedupMessageID matches copies by message ID alone, ignoring the request
+	// ID, and keeps the one with the most tokens.
+	DedupMessageID Dedup = "message-id-only"
+)
+
+// DedupStrategies lists every strategy, the default first.
+var DedupStrategies = []Dedup{DedupMaxTokens, DedupFirstSeen, DedupLastSeen, DedupMessageID}
+
+// ParseDedup returns the strategy named s.
+func ParseDedup(s string) (Dedup, error) {
+	for _, d := range DedupStrategies {
+		if string(d) == s {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown dedup strategy %q, want one of: first-seen, max-tokens, last-seen, message-id-only", s)
+}
+
+// Key returns the key copies of e are matched by. Claude Code keys requests by
+// message ID and request ID; other sources prefix their keys with their name.
+func (d Dedup) Key(e *Entry) string {
+	if d == DedupMessageID {
+		if id, _, ok := strings.Cut(e.Key, ":"); ok && id != e.Source {
+			return id
+		}
+	}
+	return e.Key
+}
+
+// Merge adds e to the dedup map under its key. Entries must be merged in a
+// stable order, e.g. by file path, so copies logged at the same second
+// resolve the same way on every run: first-seen keeps the earlier and
+// last-seen the later of them.
+func (d Dedup) Merge(all map[string]*Entry, e *Entry, stats *MergeStats) {
+	key := d.Key(e)
+	existing, ok := all[key]
+	if !ok {
+		all[key] = e
+		stats.Unique++
+		return
+	}
+	var replace bool
+	switch d {
+	case DedupFirstSeen:
+		replace = e.Timestamp < existing.Timestamp
+	case DedupLastSeen:
+		replace = e.Timestamp >= existing.Timestamp
+	default:
+		replace = e.TotalTokens() > existing.TotalTokens()
+	}
+	if replace {
+		all[key] = e
+		stats.Conflicts++
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abatilo/ccusage-go/pricing"
+	"github.com/abatilo/ccusage-go/render"
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/usage"
+)
+
+// versionUsage summarizes the requests logged by one version of a source's
+// tool, over the whole range or on one day.
+type versionUsage struct {
+	Source          string  `json:"source"`
+	Version         string  `json:"version"`
+	Date            string  `json:"date,omitempty"`
+	FirstSeen       string  `json:"first_seen,omitempty"`
+	LastSeen        string  `json:"last_seen,omitempty"`
+	Requests        int     `json:"requests"`
+	Tokens          int     `json:"tokens"`
+	CacheReadTokens int     `json:"cache_read_tokens"`
+	CostUSD         float64 `json:"cost_usd"`
+}
+
+func (v *versionUsage) add(e *usage.Entry, cost float64) {
+	if v.FirstSeen == "" || e.Date < v.FirstSeen {
+		v.FirstSeen = e.Date
+	}
+	v.LastSeen = max(v.LastSeen, e.Date)
+	v.Requests++
+	v.Tokens += e.TotalTokens()
+	v.CacheReadTokens += e.CacheReadTokens
+	v.CostUSD += cost
+}
+
+func (v *versionUsage) avgCost() float64 {
+	return v.CostUSD / float64(max(v.Requests, 1))
+}
+
+func (v *versionUsage) avgTokens() int {
+	return v.Tokens / max(v.Requests, 1)
+}
+
+// cacheReadShare is the percentage of tokens served from the prompt cache.
+func (v *versionUsage) cacheReadShare() float64 {
+	if v.Tokens == 0 {
+		return 0
+	}
+	return float64(v.CacheReadTokens) / float64(v.Tokens) * 100
+}
+
+type versionReport struct {
+	Range    string         `json:"range"`
+	Versions []versionUsage `json:"versions"`
+	Daily    []versionUsage `json:"daily"`
+}
+
+// compareVersions orders dotted version strings numerically, falling back to
+// string order for non-numeric parts.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			return na - nb
+		case (errA != nil || errB != nil) && pa[i] != pb[i]:
+			return strings.Compare(pa[i], pb[i])
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// lessVersion orders rows by source, then version.
+func lessVersion(a, b *versionUsage) bool {
+	if a.Source != b.Source {
+		return a.Source < b.Source
+	}
+	return compareVersions(a.Version, b.Version) < 0
+}
+
+func buildVersionReport(entries map[string]*usage.Entry, mode usage.CostMode) *versionReport {
+	type sourceVersion struct{ source, version string }
+	type dayVersion struct {
+		date string
+		sourceVersion
+	}
+	versions := make(map[sourceVersion]*versionUsage)
+	daily := make(map[dayVersion]*versionUsage)
+	for _, e := range entries {
+		// Versions of different tools are unrelated, so each source gets its own rows
+		sv := sourceVersion{e.Source, e.Version}
+		if sv.source == "" {
+			sv.source = source.ClaudeCode
+		}
+		if sv.version == "" {
+			sv.version = "unknown"
+		}
+		if versions[sv] == nil {
+			versions[sv] = &versionUsage{Source: sv.source, Version: sv.version}
+		}
+		cost := mode.EntryCost(e, pricing.Default)
+		versions[sv].add(e, cost)
+		k := dayVersion{e.Date, sv}
+		if daily[k] == nil {
+			daily[k] = &versionUsage{Source: sv.source, Version: sv.version, Date: e.Date}
+		}
+		daily[k].add(e, cost)
+	}
+
+	r := &versionReport{Versions: []versionUsage{}, Daily: []versionUsage{}}
+	for _, v := range versions {
+		r.Versions = append(r.Versions, *v)
+	}
+	sort.Slice(r.Versions, func(i, j int) bool {
+		return lessVersion(&r.Versions[i], &r.Versions[j])
+	})
+	for _, v := range daily {
+		// First and last seen are implied by the date
+		v.FirstSeen, v.LastSeen = "", ""
+		r.Daily = append(r.Daily, *v)
+	}
+	sort.Slice(r.Daily, func(i, j int) bool {
+		if r.Daily[i].Date != r.Daily[j].Date {
+			return r.Daily[i].Date < r.Daily[j].Date
+		}
+		return lessVersion(&r.Daily[i], &r.Daily[j])
+	})
+	return r
+}
+
+func runVersions(args []string) {
+	fs := flag.NewFlagSet("versions", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	format := fs.String("format", "table", "output format: table, json")
+	_ = fs.Parse(args)
+	opts := common.options(fs)
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "error: --format must be one of: table, json\n")
+		os.Exit(1)
+	}
+
+	entries := common.loadEntries(opts)
+	r := buildVersionReport(entries, opts.costMode)
+	r.Range = opts.describe()
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return
+	}
+	printVersionReport(r)
+}
+
+func printVersionReport(r *versionReport) {
+	versionWidth, sourceWidth := 7, 6
+	for _, v := range r.Versions {
+		versionWidth = max(versionWidth, len(v.Version))
+		sourceWidth = max(sourceWidth, len(v.Source))
+	}
+
+	fmt.Printf("Usage by version, %s\n\n", r.Range)
+	rowFormat := fmt.Sprintf("%%-%ds %%-%ds %%-10s %%-10s %%9s %%17s %%12s %%10s %%12s %%12s\n", sourceWidth, versionWidth)
+	width := sourceWidth + versionWidth + 102
+	fmt.Printf(rowFormat, "Source", "Version", "First", "Last", "Requests", "Tokens", "Tokens/Req", "CacheRead", "Cost", "Cost/Req")
+	fmt.Println(strings.Repeat("-", width))
+	prevSource := ""
+	for _, v := range r.Versions {
+		src := v.Source
+		if src == prevSource {
+			src = ""
+		}
+		prevSource = v.Source
+		fmt.Printf(rowFormat, src, v.Version, v.FirstSeen, v.LastSeen, render.FormatNumber(v.Requests), render.FormatNumber(v.Tokens),
+			render.FormatNumber(v.avgTokens()), fmt.Sprintf("%.1f%%", v.cacheReadShare()), render.FormatDollars(v.CostUSD), fmt.Sprintf("$%.4f", v.avgCost()))
+	}
+
+	// Over time: one row per day and version, so a release's effect shows up
+	// on the day it was adopted
+	fmt.Printf("\nDaily cost per request by version\n\n")
+	rowFormat = fmt.Sprintf("%%-10s %%-%ds %%-%ds %%9s %%12s %%10s %%12s %%12s\n", sourceWidth, versionWidth)
+	width = sourceWidth + versionWidth + 72
+	fmt.Printf(rowFormat, "Date", "Source", "Version", "Requests", "Tokens/Req", "CacheRead", "Cost", "Cost/Req")
+	fmt.Println(strings.Repeat("-", width))
+	prevDate := ""
+	for _, v := range r.Daily {
+		date := v.Date
+		if date == prevDate {
+			date = ""
+		}
+		prevDate = v.Date
+		fmt.Printf(rowFormat, date, v.Source, v.Version, render.FormatNumber(v.Requests), render.FormatNumber(v.avgTokens()),
+			fmt.Sprintf("%.1f%%", v.cacheReadShare()), render.FormatDollars(v.CostUSD), fmt.Sprintf("$%.4f", v.avgCost()))
+	}
+}
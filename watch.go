@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/abatilo/ccusage-go/source"
+	"github.com/abatilo/ccusage-go/store"
+)
+
+const (
+	// watchDebounce coalesces bursts of writes (streaming responses append many
+	// lines in quick succession) into a single refresh.
+	watchDebounce = 250 * time.Millisecond
+	// watchSaveInterval is how often a dirty cache is persisted while watching.
+	watchSaveInterval = 30 * time.Second
+)
+
+// drainEvents waits until no further events arrive for watchDebounce.
+func drainEvents(events <-chan struct{}) {
+	timer := time.NewTimer(watchDebounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-events:
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// watchLoop keeps the process running, refreshing the cache and redrawing the
+// report whenever a tracked directory changes. It returns on SIGINT/SIGTERM after
+// persisting the cache.
+func watchLoop(cache *store.Cache, sources source.Set, opts reportOptions) {
+	w, err := newDirWatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: watch: %v\n", err)
+		os.Exit(1)
+	}
+	w.sync(cache.Dirs)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	saveTicker := time.NewTicker(watchSaveInterval)
+	defer saveTicker.Stop()
+
+	redraw := func() {
+		fmt.Print("\033[H\033[2J")
+		printReport(cache, opts)
+		fmt.Printf("\nWatching %d directories, updated %s (Ctrl-C to exit)\n",
+			len(cache.Dirs), time.Now().Format("15:04:05"))
+	}
+	redraw()
+
+	dirty := false
+	for {
+		select {
+		case <-w.events:
+			drainEvents(w.events)
+			if refreshCache(cache, sources, true) {
+				dirty = true
+			}
+			w.sync(cache.Dirs)
+			redraw()
+		case <-saveTicker.C:
+			if dirty {
+				_ = cache.Save()
+				dirty = false
+			}
+			// Redraw so the date range rolls over at midnight
+			redraw()
+		case <-sigs:
+			if dirty {
+				_ = cache.Save()
+			}
+			return
+		}
+	}
+}
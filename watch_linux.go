@@ -0,0 +1,72 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// dirWatcher delivers a notification on events whenever a watched directory or
+// a file within it changes, using inotify.
+type dirWatcher struct {
+	fd     int
+	wds    map[string]int
+	events chan struct{}
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &dirWatcher{
+		fd:     fd,
+		wds:    make(map[string]int),
+		events: make(chan struct{}, 1),
+	}
+	go w.readLoop()
+	return w, nil
+}
+
+// readLoop blocks on the inotify descriptor. The event payloads are not decoded:
+// the cache machinery detects which files changed by mtime and size, so any event
+// only needs to trigger a refresh.
+func (w *dirWatcher) readLoop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return
+		}
+		select {
+		case w.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// sync adds watches for new directories and drops watches for removed ones.
+func (w *dirWatcher) sync(dirs map[string]int64) {
+	for dir := range dirs {
+		if _, ok := w.wds[dir]; ok {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+		if err != nil {
+			continue
+		}
+		w.wds[dir] = wd
+	}
+	for dir, wd := range w.wds {
+		if _, ok := dirs[dir]; !ok {
+			_, _ = syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.wds, dir)
+		}
+	}
+}